@@ -0,0 +1,94 @@
+// SnapUp 程序入口：默认以 HTTP 或 MCP 模式运行服务，第一个参数为子命令名时执行对应的命令行子命令
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/gotoailab/snapup/internal/cli"
+	"github.com/gotoailab/snapup/internal/mcp"
+	"github.com/gotoailab/snapup/internal/screenshot"
+	"github.com/gotoailab/snapup/internal/server"
+)
+
+const version = "1.0.0"
+
+// commands 命令行子命令，参数为子命令之后的参数
+var commands = map[string]func(args []string) error{
+	"crawl": func(args []string) error { return cli.RunCrawl(args, os.Stdout) },
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		if run, ok := commands[os.Args[1]]; ok {
+			if err := run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	mode := flag.String("mode", "http", "运行模式: http, mcp")
+	port := flag.Int("port", envInt("PORT", 8080), "HTTP 服务监听端口")
+	output := flag.String("output", envString("OUTPUT_DIR", "./screenshots"), "截图输出目录")
+	flag.Parse()
+
+	if err := os.MkdirAll(*output, 0755); err != nil {
+		log.Fatalf("创建输出目录失败: %v", err)
+	}
+
+	service := screenshot.NewService(*output)
+	defer service.Close()
+
+	var err error
+	switch *mode {
+	case "http":
+		err = server.NewServer(service, *port).Start()
+	case "mcp":
+		err = runMCP(service, *output)
+	default:
+		err = fmt.Errorf("未知的运行模式 %q，可选 http 或 mcp", *mode)
+	}
+	if err != nil {
+		service.Close()
+		log.Fatal(err)
+	}
+}
+
+// runMCP 通过 stdio 运行 MCP 服务器，收到中断信号时退出
+func runMCP(service *screenshot.Service, outputDir string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	mcpServer := mcp.NewServer("snapup", version)
+	if err := mcp.NewScreenshotToolHandler(service, outputDir).RegisterScreenshotTools(mcpServer); err != nil {
+		return fmt.Errorf("注册 MCP 工具失败: %w", err)
+	}
+	if err := mcpServer.Run(ctx); err != nil && err != context.Canceled {
+		return err
+	}
+	return nil
+}
+
+// envString 读取字符串环境变量，未设置时返回默认值
+func envString(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// envInt 读取整数环境变量，未设置或无效时返回默认值
+func envInt(key string, fallback int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n > 0 {
+		return n
+	}
+	return fallback
+}
@@ -0,0 +1,218 @@
+// Package auth API Key 认证。Key 只以 SHA-256 哈希的形式保存在内存中，
+// 配置文件中也可以只写哈希，避免明文 Key 落盘。
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 权限范围
+const (
+	ScopeAll        = "*"          // 所有权限
+	ScopeScreenshot = "screenshot" // 截图
+	ScopeRead       = "read"       // 读取设备、样式等只读信息
+)
+
+// hashPrefix 配置文件中哈希值的可选前缀
+const hashPrefix = "sha256:"
+
+// Key API Key 信息，不包含明文
+type Key struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+
+	hash [sha256.Size]byte
+}
+
+// HasScope 判断 Key 是否拥有指定权限，scope 为空表示只要求认证通过
+func (k *Key) HasScope(scope string) bool {
+	if scope == "" {
+		return true
+	}
+	for _, s := range k.Scopes {
+		if s == ScopeAll || s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyStore API Key 集合
+type KeyStore struct {
+	keys []*Key
+}
+
+// keyFile API Key 文件格式
+type keyFile struct {
+	Keys []keyEntry `json:"keys" yaml:"keys"`
+}
+
+// keyEntry 文件中的单个 Key，key 和 key_hash 二选一
+type keyEntry struct {
+	Name    string   `json:"name" yaml:"name"`
+	Key     string   `json:"key" yaml:"key"`
+	KeyHash string   `json:"key_hash" yaml:"key_hash"`
+	Scopes  []string `json:"scopes" yaml:"scopes"`
+}
+
+// HashKey 返回 Key 的 SHA-256 哈希（十六进制），用于在配置文件中保存 key_hash
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// LoadFromEnv 从环境变量加载 API Key。
+// API_KEYS_FILE 指定 Key 文件（JSON 或 YAML），API_KEYS 以逗号分隔多个 name:key[:scope|scope] 条目，
+// 未指定权限的条目拥有所有权限。两者都未配置时返回空集合，表示不启用认证。
+func LoadFromEnv() (*KeyStore, error) {
+	store := &KeyStore{}
+
+	if path := os.Getenv("API_KEYS_FILE"); path != "" {
+		if err := store.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, entry := range strings.Split(os.Getenv("API_KEYS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("API_KEYS 条目格式无效，应为 name:key[:scope|scope]")
+		}
+		scopes := []string{ScopeAll}
+		if len(parts) == 3 && parts[2] != "" {
+			scopes = strings.Split(parts[2], "|")
+		}
+		if err := store.add(parts[0], sha256.Sum256([]byte(parts[1])), scopes); err != nil {
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
+
+// loadFile 从 JSON 或 YAML 文件加载 Key
+func (s *KeyStore) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取 API Key 文件失败: %w", err)
+	}
+
+	var file keyFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	default:
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return fmt.Errorf("解析 API Key 文件失败: %w", err)
+	}
+
+	for _, entry := range file.Keys {
+		if entry.Name == "" {
+			return fmt.Errorf("API Key 缺少 name")
+		}
+
+		var hash [sha256.Size]byte
+		switch {
+		case entry.KeyHash != "" && entry.Key != "":
+			return fmt.Errorf("API Key %q 不能同时设置 key 和 key_hash", entry.Name)
+		case entry.KeyHash != "":
+			decoded, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(entry.KeyHash), hashPrefix))
+			if err != nil || len(decoded) != sha256.Size {
+				return fmt.Errorf("API Key %q 的 key_hash 不是有效的 SHA-256 十六进制值", entry.Name)
+			}
+			copy(hash[:], decoded)
+		case entry.Key != "":
+			hash = sha256.Sum256([]byte(entry.Key))
+		default:
+			return fmt.Errorf("API Key %q 缺少 key 或 key_hash", entry.Name)
+		}
+
+		if err := s.add(entry.Name, hash, entry.Scopes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add 添加 Key，名称和 Key 都不能重复
+func (s *KeyStore) add(name string, hash [sha256.Size]byte, scopes []string) error {
+	for _, k := range s.keys {
+		if k.Name == name {
+			return fmt.Errorf("API Key 名称 %q 重复", name)
+		}
+		if k.hash == hash {
+			return fmt.Errorf("API Key %q 与 %q 的 Key 相同", name, k.Name)
+		}
+	}
+
+	cleaned := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			cleaned = append(cleaned, scope)
+		}
+	}
+	sort.Strings(cleaned)
+
+	s.keys = append(s.keys, &Key{Name: name, Scopes: cleaned, hash: hash})
+	return nil
+}
+
+// Enabled 是否配置了 Key，未配置时不启用认证
+func (s *KeyStore) Enabled() bool {
+	return s != nil && len(s.keys) > 0
+}
+
+// Len 返回 Key 的数量
+func (s *KeyStore) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.keys)
+}
+
+// Authenticate 校验明文 Key，返回匹配的 Key，不匹配时返回 nil。
+// 逐个以常量时间比较哈希，避免通过响应时间推测 Key。
+func (s *KeyStore) Authenticate(key string) *Key {
+	if s == nil || key == "" {
+		return nil
+	}
+	hash := sha256.Sum256([]byte(key))
+
+	var found *Key
+	for _, k := range s.keys {
+		if subtle.ConstantTimeCompare(k.hash[:], hash[:]) == 1 {
+			found = k
+		}
+	}
+	return found
+}
+
+type contextKey struct{}
+
+// WithKey 将认证通过的 Key 存入 context
+func WithKey(ctx context.Context, key *Key) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// FromContext 返回请求认证通过的 Key，未认证时返回 nil
+func FromContext(ctx context.Context) *Key {
+	key, _ := ctx.Value(contextKey{}).(*Key)
+	return key
+}
@@ -0,0 +1,111 @@
+// Package cli 命令行子命令，由程序入口按子命令名分发
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/gotoailab/snapup/internal/models"
+	"github.com/gotoailab/snapup/internal/screenshot"
+)
+
+// listFlag 可重复指定的字符串参数
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// RunCrawl 执行 crawl 子命令：爬取站点并截图，在输出目录生成 JSON 索引和 HTML 图集。
+// args 为子命令之后的参数，进度和结果写入 stdout。有页面截图失败时返回错误，便于脚本判断。
+func RunCrawl(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("crawl", flag.ContinueOnError)
+	var (
+		req      models.CrawlRequest
+		include  listFlag
+		exclude  listFlag
+		depth    = fs.Int("depth", models.DefaultCrawlDepth, "从起始页面出发跟随链接的最大层数，0 表示只截起始页面")
+		output   = fs.String("output", "./screenshots", "截图和索引的输出目录")
+		device   = fs.String("device", "desktop", "设备类型")
+		style    = fs.String("style", "none", "截图样式")
+		format   = fs.String("format", "png", "图片格式: png, jpeg, webp")
+		fullPage = fs.Bool("full-page", false, "是否全页截图")
+		timeout  = fs.Int("timeout", 0, "每个页面的超时时间(毫秒)，0 表示使用默认值")
+	)
+	fs.StringVar(&req.URL, "url", "", "起始页面或 sitemap 的地址（必填）")
+	fs.BoolVar(&req.Sitemap, "sitemap", false, "URL 是否为 sitemap，路径以 .xml 结尾时自动识别")
+	fs.IntVar(&req.MaxPages, "max-pages", models.DefaultCrawlPages, "最多截图的页面数")
+	fs.Var(&include, "include", "只跟随匹配该正则表达式的链接，可重复指定")
+	fs.Var(&exclude, "exclude", "不跟随匹配该正则表达式的链接，可重复指定")
+	fs.SetOutput(stdout)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if req.URL == "" {
+		fs.Usage()
+		return errors.New("缺少 -url 参数")
+	}
+
+	req.MaxDepth = depth
+	req.Include = include
+	req.Exclude = exclude
+	req.Device = models.DeviceType(*device)
+	req.Style = models.MockupStyle(*style)
+	req.Format = models.ImageFormat(*format)
+	req.FullPage = *fullPage
+	req.Timeout = *timeout
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	service := screenshot.NewService(*output)
+	defer service.Close()
+
+	if err := service.ValidateCrawl(ctx, req); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "开始爬取 %s\n", req.URL)
+	resp := service.Crawl(ctx, req, func(done, total int) {
+		fmt.Fprintf(stdout, "\r已完成 %d/%d 个页面", done, total)
+	})
+	fmt.Fprintln(stdout)
+
+	for _, page := range resp.Pages {
+		if page.Success {
+			fmt.Fprintf(stdout, "  ✓ [%d] %s -> %s\n", page.Depth, page.URL, page.Filename)
+		} else {
+			fmt.Fprintf(stdout, "  ✗ [%d] %s: [%s] %s\n", page.Depth, page.URL, page.Code, page.Message)
+		}
+	}
+	fmt.Fprintf(stdout, "共 %d 个页面，成功 %d，失败 %d", resp.Total, resp.Succeeded, resp.Failed)
+	if resp.Truncated {
+		fmt.Fprintf(stdout, "（发现 %d 个页面，已达到页面数上限）", resp.Discovered)
+	}
+	fmt.Fprintln(stdout)
+	if resp.IndexURL != "" {
+		fmt.Fprintf(stdout, "图集: %s\n", filepath.Join(*output, filepath.Base(resp.IndexURL)))
+		fmt.Fprintf(stdout, "索引: %s\n", filepath.Join(*output, filepath.Base(resp.IndexJSON)))
+	}
+
+	switch {
+	case resp.Status == models.BatchSuccess:
+		return nil
+	case resp.Message != "":
+		return errors.New(resp.Message)
+	default:
+		return fmt.Errorf("%d 个页面截图失败", resp.Failed)
+	}
+}
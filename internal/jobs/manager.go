@@ -0,0 +1,327 @@
+// Package jobs 异步任务管理：任务在后台执行，客户端通过任务 ID 轮询状态和结果。
+// 任务结束后保留一段时间（TTL），过期后自动清理。
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gotoailab/snapup/internal/screenshot"
+)
+
+// Status 任务状态
+type Status string
+
+const (
+	StatusQueued   Status = "queued"   // 等待执行
+	StatusRunning  Status = "running"  // 执行中
+	StatusDone     Status = "done"     // 执行成功
+	StatusFailed   Status = "failed"   // 执行失败
+	StatusCanceled Status = "canceled" // 已取消
+)
+
+// Finished 任务是否已结束
+func (s Status) Finished() bool {
+	return s == StatusDone || s == StatusFailed || s == StatusCanceled
+}
+
+// ErrNotFound 任务不存在、已过期或不属于当前客户端
+var ErrNotFound = errors.New("任务不存在或已过期")
+
+// ErrTooManyJobs 未结束的任务数已达上限，需等待之前的任务完成后再提交
+var ErrTooManyJobs = errors.New("未结束的任务过多")
+
+// Config 任务管理配置
+type Config struct {
+	TTL          time.Duration // 任务结束后保留的时间
+	MaxPending   int           // 排队中和执行中的任务总数上限，0 表示不限制
+	MaxPerClient int           // 每个客户端排队中和执行中的任务数上限，0 表示不限制
+}
+
+// ConfigFromEnv 从环境变量读取任务管理配置
+func ConfigFromEnv() Config {
+	ttl := time.Hour
+	if value := os.Getenv("JOB_TTL"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			ttl = d
+		} else {
+			log.Printf("环境变量 JOB_TTL 不是有效的时长: %q，使用默认值 %s", value, ttl)
+		}
+	}
+	return Config{
+		TTL:          ttl,
+		MaxPending:   envInt("MAX_PENDING_JOBS", 100),
+		MaxPerClient: envInt("MAX_PENDING_JOBS_PER_CLIENT", 10),
+	}
+}
+
+// envInt 读取非负整数环境变量，未设置或无效时使用默认值
+func envInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("环境变量 %s 不是有效的非负整数: %q，使用默认值 %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+// Func 任务的执行函数。返回的 result 会原样放入任务结果，
+// failed 为 true 表示任务执行失败（如截图失败），但结果仍然有效。
+type Func func(ctx context.Context) (result interface{}, failed bool, err error)
+
+// Job 任务快照
+type Job struct {
+	ID            string      `json:"id"`
+	Type          string      `json:"type"`
+	Status        Status      `json:"status"`
+	QueuePosition int         `json:"queue_position,omitempty"` // 排队中时的当前位置
+	Progress      *Progress   `json:"progress,omitempty"`
+	Result        interface{} `json:"result,omitempty"`
+	Error         string      `json:"error,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+	StartedAt     *time.Time  `json:"started_at,omitempty"`
+	FinishedAt    *time.Time  `json:"finished_at,omitempty"`
+	ExpiresAt     *time.Time  `json:"expires_at,omitempty"` // 任务结束后的过期时间
+}
+
+// Progress 多步骤任务的进度
+type Progress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// job 管理器内部的任务状态
+type job struct {
+	Job
+	owner  string
+	cancel context.CancelFunc
+}
+
+// Manager 异步任务管理器
+type Manager struct {
+	config Config
+
+	mu        sync.Mutex
+	jobs      map[string]*job
+	pending   int            // 排队中和执行中的任务数
+	perClient map[string]int // 每个客户端排队中和执行中的任务数
+}
+
+// NewManager 创建任务管理器，并在后台定期清理过期任务
+func NewManager(config Config) *Manager {
+	m := &Manager{
+		config:    config,
+		jobs:      make(map[string]*job),
+		perClient: make(map[string]int),
+	}
+	go m.cleanupLoop()
+	return m
+}
+
+// Submit 提交任务并立即在后台执行。owner 为提交任务的客户端，只有同一客户端可以查询和取消任务。
+// 任务在独立的 context 中执行，不随提交任务的 HTTP 请求结束而取消；任务中的截图受客户端并发上限限制时排队等待。
+// 未结束的任务总数或该客户端未结束的任务数达到上限时返回包装了 ErrTooManyJobs 的错误。
+func (m *Manager) Submit(owner, jobType string, fn Func) (Job, error) {
+	m.mu.Lock()
+	if m.config.MaxPerClient > 0 && m.perClient[owner] >= m.config.MaxPerClient {
+		m.mu.Unlock()
+		return Job{}, fmt.Errorf("%w，每个客户端最多同时有 %d 个排队中或执行中的任务，请等待之前的任务完成", ErrTooManyJobs, m.config.MaxPerClient)
+	}
+	if m.config.MaxPending > 0 && m.pending >= m.config.MaxPending {
+		m.mu.Unlock()
+		return Job{}, fmt.Errorf("%w，服务器繁忙（%d 个任务排队中或执行中），请稍后重试", ErrTooManyJobs, m.pending)
+	}
+	m.pending++
+	m.perClient[owner]++
+	m.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(screenshot.WithQueueWait(screenshot.WithClient(context.Background(), owner)))
+	j := &job{
+		Job: Job{
+			ID:        uuid.New().String(),
+			Type:      jobType,
+			Status:    StatusQueued,
+			CreatedAt: time.Now(),
+		},
+		owner:  owner,
+		cancel: cancel,
+	}
+
+	m.mu.Lock()
+	m.jobs[j.ID] = j
+	snapshot := j.snapshot()
+	m.mu.Unlock()
+
+	// 截图进入队列后更新排队位置，开始执行时切换为 running
+	ctx = screenshot.WithQueueObserver(ctx, func(position int) {
+		m.update(j, func() {
+			if j.Status != StatusQueued && j.Status != StatusRunning {
+				return
+			}
+			if position > 0 && j.StartedAt == nil {
+				j.QueuePosition = position
+				return
+			}
+			j.QueuePosition = 0
+			if j.StartedAt == nil {
+				now := time.Now()
+				j.StartedAt = &now
+				j.Status = StatusRunning
+			}
+		})
+	})
+	ctx = withProgress(ctx, func(done, total int) {
+		m.update(j, func() {
+			j.Progress = &Progress{Done: done, Total: total}
+		})
+	})
+
+	go m.run(ctx, j, fn)
+	return snapshot, nil
+}
+
+// run 执行任务并记录结果
+func (m *Manager) run(ctx context.Context, j *job, fn Func) {
+	defer j.cancel()
+
+	result, failed, err := func() (result interface{}, failed bool, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("任务 %s 执行时发生 panic: %v", j.ID, r)
+				err = errors.New("任务执行异常")
+			}
+		}()
+		return fn(ctx)
+	}()
+
+	// 任务名额在执行结束后才归还，被取消的任务在 fn 返回前仍占用截图和任务名额
+	m.update(j, func() {
+		defer m.release(j)
+		if j.Status == StatusCanceled {
+			return
+		}
+		j.Result = result
+		switch {
+		case err != nil:
+			j.Status = StatusFailed
+			j.Error = err.Error()
+		case failed:
+			j.Status = StatusFailed
+		default:
+			j.Status = StatusDone
+		}
+		m.finish(j)
+	})
+}
+
+// Get 返回任务快照
+func (m *Manager) Get(owner, id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok || j.owner != owner {
+		return Job{}, ErrNotFound
+	}
+	return j.snapshot(), nil
+}
+
+// Cancel 取消任务，已结束的任务保持原状态。任务名额在任务实际停止执行后归还。
+func (m *Manager) Cancel(owner, id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok || j.owner != owner {
+		return Job{}, ErrNotFound
+	}
+	if !j.Status.Finished() {
+		j.cancel()
+		j.Status = StatusCanceled
+		j.QueuePosition = 0
+		m.finish(j)
+	}
+	return j.snapshot(), nil
+}
+
+// update 在锁内修改任务
+func (m *Manager) update(j *job, fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn()
+}
+
+// finish 记录任务结束和过期时间，需持有锁，每个任务只调用一次
+func (m *Manager) finish(j *job) {
+	now := time.Now()
+	expires := now.Add(m.config.TTL)
+	j.FinishedAt = &now
+	j.ExpiresAt = &expires
+}
+
+// release 归还任务名额，需持有锁，在任务执行结束时调用一次
+func (m *Manager) release(j *job) {
+	m.pending--
+	if m.perClient[j.owner]--; m.perClient[j.owner] <= 0 {
+		delete(m.perClient, j.owner)
+	}
+}
+
+// snapshot 返回任务的副本，需持有锁
+func (j *job) snapshot() Job {
+	s := j.Job
+	if j.Progress != nil {
+		progress := *j.Progress
+		s.Progress = &progress
+	}
+	return s
+}
+
+// cleanupLoop 定期删除过期任务
+func (m *Manager) cleanupLoop() {
+	interval := m.config.TTL / 10
+	if interval < time.Second {
+		interval = time.Second
+	}
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		m.mu.Lock()
+		for id, j := range m.jobs {
+			if j.ExpiresAt != nil && now.After(*j.ExpiresAt) {
+				delete(m.jobs, id)
+			}
+		}
+		m.mu.Unlock()
+	}
+}
+
+type progressKey struct{}
+
+// withProgress 在 context 中注册进度回调
+func withProgress(ctx context.Context, report func(done, total int)) context.Context {
+	return context.WithValue(ctx, progressKey{}, report)
+}
+
+// ReportProgress 报告多步骤任务的进度，context 不属于任务时不做任何事
+func ReportProgress(ctx context.Context, done, total int) {
+	if report, ok := ctx.Value(progressKey{}).(func(done, total int)); ok {
+		report(done, total)
+	}
+}
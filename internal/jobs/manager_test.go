@@ -0,0 +1,134 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// blockingFunc 返回一直执行到 release 关闭或 context 取消后、再等待 exit 关闭才返回的任务
+func blockingFunc(release, exit <-chan struct{}) Func {
+	return func(ctx context.Context) (interface{}, bool, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		<-exit
+		return "ok", false, nil
+	}
+}
+
+// waitFor 等待任务进入指定状态
+func waitFor(t *testing.T, m *Manager, owner, id string, status Status) Job {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		j, err := m.Get(owner, id)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if j.Status == status {
+			return j
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("任务状态为 %s，等待 %s 超时", j.Status, status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSubmitLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		owners []string
+		want   []bool // 每次提交是否被接受
+	}{
+		{"不限制", Config{TTL: time.Minute}, []string{"a", "a", "a"}, []bool{true, true, true}},
+		{"每个客户端上限", Config{TTL: time.Minute, MaxPerClient: 2}, []string{"a", "a", "a", "b"}, []bool{true, true, false, true}},
+		{"总数上限", Config{TTL: time.Minute, MaxPending: 2}, []string{"a", "b", "c"}, []bool{true, true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(tt.config)
+			release := make(chan struct{})
+			defer close(release)
+			exit := make(chan struct{})
+			close(exit)
+
+			for i, owner := range tt.owners {
+				_, err := m.Submit(owner, "test", blockingFunc(release, exit))
+				if accepted := err == nil; accepted != tt.want[i] {
+					t.Fatalf("第 %d 次提交 accepted = %v, want %v (err = %v)", i, accepted, tt.want[i], err)
+				}
+				if err != nil && !errors.Is(err, ErrTooManyJobs) {
+					t.Fatalf("err = %v, want ErrTooManyJobs", err)
+				}
+			}
+		})
+	}
+}
+
+func TestFinishedJobReleasesSlot(t *testing.T) {
+	m := NewManager(Config{TTL: time.Minute, MaxPerClient: 1})
+	release, exit := make(chan struct{}), make(chan struct{})
+	close(exit)
+
+	j, err := m.Submit("a", "test", blockingFunc(release, exit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	done := waitFor(t, m, "a", j.ID, StatusDone)
+	if done.Result != "ok" || done.FinishedAt == nil || done.ExpiresAt == nil {
+		t.Fatalf("job = %+v", done)
+	}
+	if _, err := m.Submit("a", "test", blockingFunc(release, exit)); err != nil {
+		t.Fatalf("任务结束后应归还名额: %v", err)
+	}
+}
+
+func TestCancelKeepsSlotUntilJobReturns(t *testing.T) {
+	m := NewManager(Config{TTL: time.Minute, MaxPerClient: 1})
+	release, exit := make(chan struct{}), make(chan struct{})
+	defer close(release)
+
+	j, err := m.Submit("a", "test", blockingFunc(release, exit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Cancel("b", j.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("其他客户端取消任务 err = %v, want ErrNotFound", err)
+	}
+	canceled, err := m.Cancel("a", j.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if canceled.Status != StatusCanceled || canceled.FinishedAt == nil {
+		t.Fatalf("job = %+v", canceled)
+	}
+
+	// 任务仍在执行，名额尚未归还
+	if _, err := m.Submit("a", "test", blockingFunc(release, exit)); !errors.Is(err, ErrTooManyJobs) {
+		t.Fatalf("任务返回前提交 err = %v, want ErrTooManyJobs", err)
+	}
+
+	close(exit)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, err := m.Submit("a", "test", blockingFunc(release, exit))
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("任务返回后名额未归还: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// 被取消的任务保持取消状态，不记录结果
+	if got, _ := m.Get("a", j.ID); got.Status != StatusCanceled || got.Result != nil {
+		t.Fatalf("job = %+v", got)
+	}
+}
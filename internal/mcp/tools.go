@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/gotoailab/snapup/internal/models"
 	"github.com/gotoailab/snapup/internal/screenshot"
@@ -31,7 +32,7 @@ func (h *ScreenshotToolHandler) RegisterScreenshotTools(server *Server) error {
 	// 注册截图工具
 	screenshotTool := Tool{
 		Name:        "take_screenshot",
-		Description: "获取指定网站的屏幕截图。支持不同设备尺寸（桌面、笔记本、平板、手机）和样式（无样式、玻璃风格、设备边框、浮动阴影）。返回 base64 编码的 PNG 图片。",
+		Description: "获取指定网站的屏幕截图。支持不同设备尺寸（内置桌面、笔记本、平板、手机，以及自定义设备预设）和样式（无样式、玻璃风格、设备边框、浮动阴影）。返回 base64 编码的图片（PNG、JPEG 或 WebP）。",
 	}
 
 	// 定义工具输入模式
@@ -45,9 +46,40 @@ func (h *ScreenshotToolHandler) RegisterScreenshotTools(server *Server) error {
 			"device": map[string]interface{}{
 				"type":        "string",
 				"description": "设备类型",
-				"enum":        []string{"desktop", "laptop", "tablet", "mobile"},
+				"enum":        h.service.Devices().Types(),
 				"default":     "desktop",
 			},
+			"width": map[string]interface{}{
+				"type":        "integer",
+				"description": "自定义视口宽度（CSS 像素），覆盖设备预设",
+				"minimum":     models.MinViewportSize,
+				"maximum":     models.MaxViewportWidth,
+			},
+			"height": map[string]interface{}{
+				"type":        "integer",
+				"description": "自定义视口高度（CSS 像素），覆盖设备预设",
+				"minimum":     models.MinViewportSize,
+				"maximum":     models.MaxViewportHeight,
+			},
+			"device_scale_factor": map[string]interface{}{
+				"type":        "number",
+				"description": "自定义设备像素比，覆盖设备预设",
+				"minimum":     models.MinScaleFactor,
+				"maximum":     models.MaxScaleFactor,
+			},
+			"mobile": map[string]interface{}{
+				"type":        "boolean",
+				"description": "是否模拟移动设备，覆盖设备预设",
+			},
+			"orientation": map[string]interface{}{
+				"type":        "string",
+				"description": "屏幕方向，与预设方向不同时交换视口宽高",
+				"enum":        []string{models.OrientationPortrait, models.OrientationLandscape},
+			},
+			"user_agent": map[string]interface{}{
+				"type":        "string",
+				"description": "自定义 User-Agent，覆盖设备预设",
+			},
 			"style": map[string]interface{}{
 				"type":        "string",
 				"description": "截图样式",
@@ -59,25 +91,108 @@ func (h *ScreenshotToolHandler) RegisterScreenshotTools(server *Server) error {
 				"description": "是否截取全页（整个页面内容）还是仅可见区域",
 				"default":     false,
 			},
+			"selector": map[string]interface{}{
+				"type":        "string",
+				"description": "只截取匹配该 CSS 选择器的第一个元素，指定后忽略 full_page",
+			},
+			"padding": map[string]interface{}{
+				"type":        "integer",
+				"description": "元素截图时四周额外保留的边距（CSS 像素）",
+				"default":     0,
+				"minimum":     0,
+				"maximum":     models.MaxElementPadding,
+			},
+			"clip": map[string]interface{}{
+				"type":        "object",
+				"description": "截取页面中的指定矩形区域（CSS 像素，以文档左上角为原点），不能与 selector 同时使用",
+				"properties": map[string]interface{}{
+					"x":      map[string]interface{}{"type": "number", "minimum": 0},
+					"y":      map[string]interface{}{"type": "number", "minimum": 0},
+					"width":  map[string]interface{}{"type": "number", "minimum": 1, "maximum": models.MaxClipSize},
+					"height": map[string]interface{}{"type": "number", "minimum": 1, "maximum": models.MaxClipSize},
+				},
+				"required": []string{"x", "y", "width", "height"},
+			},
 			"delay": map[string]interface{}{
 				"type":        "integer",
-				"description": "截图前的延迟时间（毫秒），用于等待页面加载完成",
+				"description": "等待条件满足后的额外稳定时间（毫秒）",
 				"default":     1000,
 				"minimum":     0,
 				"maximum":     30000,
 			},
+			"wait": map[string]interface{}{
+				"type":        "array",
+				"description": "截图前依次等待的条件，不传时等待 load 事件",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"type": map[string]interface{}{
+							"type":        "string",
+							"description": "条件类型：load（load 事件）、network_idle（网络空闲）、selector（元素可见）、expression（JS 表达式为真）、fonts（字体加载完成）",
+							"enum":        []string{"load", "network_idle", "selector", "expression", "fonts"},
+						},
+						"value":     map[string]interface{}{"type": "string", "description": "CSS 选择器（selector）或 JS 表达式（expression）"},
+						"idle_time": map[string]interface{}{"type": "integer", "description": "无请求持续多久视为网络空闲（毫秒），仅 network_idle 使用", "default": models.DefaultNetworkIdle},
+						"timeout":   map[string]interface{}{"type": "integer", "description": "超时时间（毫秒）", "default": models.DefaultWaitTimeout, "maximum": models.MaxWaitTimeout},
+					},
+					"required": []string{"type"},
+				},
+			},
+			"accept_status": map[string]interface{}{
+				"type":        "object",
+				"description": "可接受的页面 HTTP 状态码范围（包含两端），超出范围时返回 http_error_status 错误，默认 200-399",
+				"properties": map[string]interface{}{
+					"min": map[string]interface{}{"type": "integer", "minimum": 100, "maximum": 599},
+					"max": map[string]interface{}{"type": "integer", "minimum": 100, "maximum": 599},
+				},
+				"required": []string{"min", "max"},
+			},
+			"timeout": map[string]interface{}{
+				"type":        "integer",
+				"description": "整个截图请求的超时时间（毫秒），不传时使用服务默认值",
+				"minimum":     0,
+				"maximum":     h.service.MaxTimeout().Milliseconds(),
+			},
 			"quality": map[string]interface{}{
 				"type":        "integer",
-				"description": "图片质量（1-100）",
+				"description": "图片质量（1-100），仅对 jpeg 和 webp 格式生效",
 				"default":     90,
 				"minimum":     1,
 				"maximum":     100,
 			},
+			"format": map[string]interface{}{
+				"type":        "string",
+				"description": "输出图片格式",
+				"enum":        []string{"png", "jpeg", "webp"},
+				"default":     "png",
+			},
 			"background": map[string]interface{}{
 				"type":        "string",
 				"description": "背景颜色（十六进制格式，如 #f0f2f5，或预定义颜色名称）",
 				"default":     "#f0f2f5",
 			},
+			"shadow": map[string]interface{}{
+				"type":        "object",
+				"description": "浮动阴影参数（仅 floating 样式生效），未指定的字段使用默认值",
+				"properties": map[string]interface{}{
+					"blur":     map[string]interface{}{"type": "integer", "description": "模糊半径（像素），0 表示不模糊", "default": 40, "minimum": 0, "maximum": 200},
+					"offset_x": map[string]interface{}{"type": "integer", "description": "水平偏移（像素）", "default": 0, "minimum": -500, "maximum": 500},
+					"offset_y": map[string]interface{}{"type": "integer", "description": "垂直偏移（像素）", "default": 20, "minimum": -500, "maximum": 500},
+					"spread":   map[string]interface{}{"type": "integer", "description": "扩展距离（像素），负值收缩阴影", "default": 0, "minimum": -500, "maximum": 500},
+					"color":    map[string]interface{}{"type": "string", "description": "阴影颜色", "default": "#000000"},
+					"opacity":  map[string]interface{}{"type": "number", "description": "不透明度（0-1）", "default": 0.35, "minimum": 0, "maximum": 1},
+				},
+			},
+			"glass": map[string]interface{}{
+				"type":        "object",
+				"description": "玻璃效果参数（仅 glass 样式生效），未指定的字段使用默认值",
+				"properties": map[string]interface{}{
+					"blur":             map[string]interface{}{"type": "integer", "description": "背景模糊半径（像素），0 表示不模糊", "default": 40, "minimum": 0, "maximum": 200},
+					"opacity":          map[string]interface{}{"type": "number", "description": "玻璃卡片不透明度（0-1），0 表示完全透明", "default": 0.25, "minimum": 0, "maximum": 1},
+					"highlight":        map[string]interface{}{"type": "boolean", "description": "是否绘制高光渐变", "default": true},
+					"background_image": map[string]interface{}{"type": "string", "description": "背景图片（base64 或 data URI，不超过 10MB、2500 万像素），不传时使用模糊放大的截图"},
+				},
+			},
 		},
 		Required: []string{"url"},
 	}
@@ -92,6 +207,167 @@ func (h *ScreenshotToolHandler) RegisterScreenshotTools(server *Server) error {
 	// 注册工具
 	server.RegisterTool(screenshotTool, h.handleTakeScreenshot)
 
+	// 注册多设备合成图工具
+	compositeTool := Tool{
+		Name:        "take_responsive_screenshot",
+		Description: "在多个设备上截取同一个网站，为每个设备套用设备边框后合成到一张图中，用于展示响应式效果。返回 base64 编码的合成图。",
+	}
+
+	compositeSchema := ToolInput{
+		Type: "object",
+		Properties: map[string]interface{}{
+			"url": map[string]interface{}{
+				"type":        "string",
+				"description": "要截图的网站 URL（必须包含 http:// 或 https://）",
+			},
+			"devices": map[string]interface{}{
+				"type":        "array",
+				"description": "参与合成的设备，默认为桌面、笔记本、平板和手机",
+				"items":       map[string]interface{}{"type": "string", "enum": h.service.Devices().Types()},
+				"maxItems":    models.MaxCompositeDevices,
+			},
+			"layout": map[string]interface{}{
+				"type":        "string",
+				"description": "布局：showcase（最大的设备居中，其余设备叠放在两侧前方）、grid（等大网格）、row（按真实比例排成一行）",
+				"enum":        []string{string(models.LayoutShowcase), string(models.LayoutGrid), string(models.LayoutRow)},
+				"default":     string(models.LayoutShowcase),
+			},
+			"spacing": map[string]interface{}{
+				"type":        "integer",
+				"description": "设备之间及画布四周的间距（像素）",
+				"default":     models.DefaultCompositeSpace,
+				"minimum":     0,
+				"maximum":     models.MaxCompositeSpace,
+			},
+			"columns": map[string]interface{}{
+				"type":        "integer",
+				"description": "grid 布局的列数，不传时自动计算",
+				"minimum":     1,
+			},
+			"canvas_width": map[string]interface{}{
+				"type":        "integer",
+				"description": "合成图宽度（像素），不传时按设备真实尺寸排版，最宽不超过默认宽度",
+				"maximum":     models.MaxCanvasWidth,
+			},
+			"background": map[string]interface{}{
+				"type":        "string",
+				"description": "背景颜色",
+				"default":     "#f0f2f5",
+			},
+			"format": map[string]interface{}{
+				"type":        "string",
+				"description": "输出格式",
+				"enum":        []string{"png", "jpeg", "webp"},
+				"default":     "png",
+			},
+			"quality": map[string]interface{}{
+				"type":        "integer",
+				"description": "图片质量（1-100），仅对 jpeg 和 webp 生效",
+				"default":     90,
+				"minimum":     1,
+				"maximum":     100,
+			},
+			"delay": map[string]interface{}{
+				"type":        "integer",
+				"description": "页面加载后的额外等待时间（毫秒）",
+				"default":     0,
+			},
+			"timeout": map[string]interface{}{
+				"type":        "integer",
+				"description": "每个设备截图的超时时间（毫秒），不传时使用服务默认值",
+				"maximum":     h.service.MaxTimeout().Milliseconds(),
+			},
+		},
+		Required: []string{"url"},
+	}
+
+	compositeSchemaBytes, err := json.Marshal(compositeSchema)
+	if err != nil {
+		return fmt.Errorf("序列化合成图输入模式失败: %w", err)
+	}
+
+	compositeTool.InputSchema = compositeSchemaBytes
+	server.RegisterTool(compositeTool, h.handleTakeComposite)
+
+	// 注册截图对比工具
+	compareTool := Tool{
+		Name:        "compare_screenshots",
+		Description: "逐像素比较两张截图（现场截取的 URL 或已保存的截图文件名），忽略抗锯齿造成的差异，返回不同像素所占的百分比和标出差异的 PNG 差异图。尺寸不同时按左上角对齐，多出的区域计为不同。",
+	}
+
+	source := func(description string) map[string]interface{} {
+		return map[string]interface{}{
+			"type":        "object",
+			"description": description + "，url 和 filename 只能指定一个",
+			"properties": map[string]interface{}{
+				"url": map[string]interface{}{
+					"type":        "string",
+					"description": "要截图的网站 URL（必须包含 http:// 或 https://）",
+				},
+				"filename": map[string]interface{}{
+					"type":        "string",
+					"description": "已保存的截图文件名（PNG 或 JPEG），如 take_screenshot 返回的文件名",
+				},
+			},
+		}
+	}
+	compareSchema := ToolInput{
+		Type: "object",
+		Properties: map[string]interface{}{
+			"before": source("对比的基准图片"),
+			"after":  source("对比的新图片"),
+			"threshold": map[string]interface{}{
+				"type":        "number",
+				"description": "单个像素的颜色差异容差，越大越宽松",
+				"default":     models.DefaultDiffThreshold,
+				"minimum":     0,
+				"maximum":     1,
+			},
+			"include_aa": map[string]interface{}{
+				"type":        "boolean",
+				"description": "是否把抗锯齿造成的差异也计为不同像素",
+				"default":     false,
+			},
+			"max_mismatch": map[string]interface{}{
+				"type":        "number",
+				"description": "允许的不同像素百分比，不超过时判定为通过",
+				"default":     0,
+				"minimum":     0,
+				"maximum":     100,
+			},
+			"device": map[string]interface{}{
+				"type":        "string",
+				"description": "截取 URL 时使用的设备类型",
+				"enum":        h.service.Devices().Types(),
+				"default":     "desktop",
+			},
+			"full_page": map[string]interface{}{
+				"type":        "boolean",
+				"description": "截取 URL 时是否截取全页",
+				"default":     false,
+			},
+			"delay": map[string]interface{}{
+				"type":        "integer",
+				"description": "截取 URL 时页面加载后的额外等待时间（毫秒）",
+				"default":     0,
+			},
+			"timeout": map[string]interface{}{
+				"type":        "integer",
+				"description": "每次截图的超时时间（毫秒），不传时使用服务默认值",
+				"maximum":     h.service.MaxTimeout().Milliseconds(),
+			},
+		},
+		Required: []string{"before", "after"},
+	}
+
+	compareSchemaBytes, err := json.Marshal(compareSchema)
+	if err != nil {
+		return fmt.Errorf("序列化截图对比输入模式失败: %w", err)
+	}
+
+	compareTool.InputSchema = compareSchemaBytes
+	server.RegisterTool(compareTool, h.handleCompareScreenshots)
+
 	// 注册设备信息工具
 	devicesInfoTool := Tool{
 		Name:        "get_devices_info",
@@ -154,6 +430,21 @@ func (h *ScreenshotToolHandler) handleTakeScreenshot(ctx context.Context, argume
 		device = "desktop"
 	}
 
+	var width, height int64
+	if w, ok := arguments["width"].(float64); ok {
+		width = int64(w)
+	}
+	if ht, ok := arguments["height"].(float64); ok {
+		height = int64(ht)
+	}
+	scale, _ := arguments["device_scale_factor"].(float64)
+	var mobile *bool
+	if m, ok := arguments["mobile"].(bool); ok {
+		mobile = &m
+	}
+	orientation, _ := arguments["orientation"].(string)
+	userAgent, _ := arguments["user_agent"].(string)
+
 	style, _ := arguments["style"].(string)
 	if style == "" {
 		style = "none"
@@ -171,20 +462,104 @@ func (h *ScreenshotToolHandler) handleTakeScreenshot(ctx context.Context, argume
 		quality = int(q)
 	}
 
+	format, _ := arguments["format"].(string)
+
 	background, _ := arguments["background"].(string)
 	if background == "" {
 		background = "#f0f2f5"
 	}
 
+	timeout := 0
+	if t, ok := arguments["timeout"].(float64); ok {
+		timeout = int(t)
+	}
+
+	var acceptStatus *models.StatusRange
+	if err := decodeArgument(arguments, "accept_status", &acceptStatus); err != nil {
+		return &CallToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("错误：accept_status 参数无效: %v", err),
+			}},
+			IsError: true,
+		}, nil
+	}
+
+	selector, _ := arguments["selector"].(string)
+	padding := 0
+	if p, ok := arguments["padding"].(float64); ok {
+		padding = int(p)
+	}
+
+	var clip *models.ClipRect
+	if err := decodeArgument(arguments, "clip", &clip); err != nil {
+		return &CallToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("错误：clip 参数无效: %v", err),
+			}},
+			IsError: true,
+		}, nil
+	}
+
+	var wait []models.WaitCondition
+	if err := decodeArgument(arguments, "wait", &wait); err != nil {
+		return &CallToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("错误：wait 参数无效: %v", err),
+			}},
+			IsError: true,
+		}, nil
+	}
+
+	var shadow *models.ShadowSpec
+	if err := decodeArgument(arguments, "shadow", &shadow); err != nil {
+		return &CallToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("错误：shadow 参数无效: %v", err),
+			}},
+			IsError: true,
+		}, nil
+	}
+
+	var glass *models.GlassSpec
+	if err := decodeArgument(arguments, "glass", &glass); err != nil {
+		return &CallToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("错误：glass 参数无效: %v", err),
+			}},
+			IsError: true,
+		}, nil
+	}
+
 	// 创建截图请求
 	req := models.ScreenshotRequest{
-		URL:        url,
-		Device:     models.DeviceType(device),
-		Style:      models.MockupStyle(style),
-		Delay:      delay,
-		FullPage:   fullPage,
-		Quality:    quality,
-		Background: background,
+		URL:          url,
+		Device:       models.DeviceType(device),
+		Style:        models.MockupStyle(style),
+		Delay:        delay,
+		Wait:         wait,
+		Timeout:      timeout,
+		AcceptStatus: acceptStatus,
+		Selector:     selector,
+		Padding:      padding,
+		Clip:         clip,
+		FullPage:     fullPage,
+		Quality:      quality,
+		Format:       models.ImageFormat(format),
+		Background:   background,
+		Shadow:       shadow,
+		Glass:        glass,
+
+		Width:             width,
+		Height:            height,
+		DeviceScaleFactor: scale,
+		Mobile:            mobile,
+		Orientation:       orientation,
+		UserAgent:         userAgent,
 	}
 
 	// 执行截图
@@ -203,7 +578,7 @@ func (h *ScreenshotToolHandler) handleTakeScreenshot(ctx context.Context, argume
 		return &CallToolResult{
 			Content: []Content{{
 				Type: "text",
-				Text: fmt.Sprintf("截图失败: %s", resp.Message),
+				Text: fmt.Sprintf("截图失败 [%s]: %s%s%s%s", resp.Code, resp.Message, formatPageInfo(resp.Page), formatTimings(resp.Timings), formatQueue(resp.Queue)),
 			}},
 			IsError: true,
 		}, nil
@@ -225,23 +600,22 @@ func (h *ScreenshotToolHandler) handleTakeScreenshot(ctx context.Context, argume
 	// 转换为 base64
 	base64Image := base64.StdEncoding.EncodeToString(imageData)
 
-	// 获取设备配置信息
-	deviceConfig := models.GetDeviceConfig(req.Device)
-
 	// 返回结果
 	resultText := fmt.Sprintf(`截图成功！
 
 URL: %s
-设备: %s (%dx%d)
+设备: %s (%dx%d @%gx)
 样式: %s
 全页截图: %v
 延迟: %d 毫秒
 质量: %d%%
-文件名: %s
+格式: %s
+文件名: %s%s%s%s
 
-图片已生成为 base64 编码的 PNG 格式。`,
-		url, device, deviceConfig.Width, deviceConfig.Height,
-		style, fullPage, delay, quality, resp.Filename)
+图片已生成为 base64 编码的 %s 格式。`,
+		url, device, resp.Width, resp.Height, resp.Scale,
+		resp.Style, fullPage, delay, quality, resp.Format, resp.Filename,
+		formatPageInfo(resp.Page), formatTimings(resp.Timings), formatQueue(resp.Queue), strings.ToUpper(string(resp.Format)))
 
 	return &CallToolResult{
 		Content: []Content{
@@ -252,56 +626,177 @@ URL: %s
 			{
 				Type:     "image",
 				Data:     base64Image,
-				MimeType: "image/png",
+				MimeType: resp.Format.MimeType(),
 			},
 		},
 		IsError: false,
 	}, nil
 }
 
-// handleGetDevicesInfo 处理获取设备信息请求
-func (h *ScreenshotToolHandler) handleGetDevicesInfo(ctx context.Context, arguments map[string]interface{}) (*CallToolResult, error) {
-	devices := []struct {
-		Type   string
-		Name   string
-		Width  int64
-		Height int64
-		Mobile bool
-	}{
-		{
-			Type:   "desktop",
-			Name:   "桌面",
-			Width:  1920,
-			Height: 1080,
-			Mobile: false,
-		},
-		{
-			Type:   "laptop",
-			Name:   "笔记本",
-			Width:  1440,
-			Height: 900,
-			Mobile: false,
-		},
-		{
-			Type:   "tablet",
-			Name:   "平板",
-			Width:  768,
-			Height: 1024,
-			Mobile: true,
-		},
-		{
-			Type:   "mobile",
-			Name:   "手机",
-			Width:  375,
-			Height: 812,
-			Mobile: true,
+// handleTakeComposite 处理多设备合成图请求
+func (h *ScreenshotToolHandler) handleTakeComposite(ctx context.Context, arguments map[string]interface{}) (*CallToolResult, error) {
+	// 参数名与 HTTP 接口的 JSON 字段一致，直接解码
+	var req models.CompositeRequest
+	data, err := json.Marshal(arguments)
+	if err == nil {
+		err = json.Unmarshal(data, &req)
+	}
+	if err != nil {
+		return &CallToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("错误：参数无效: %v", err),
+			}},
+			IsError: true,
+		}, nil
+	}
+
+	resp := h.service.TakeComposite(ctx, req)
+	if !resp.Success {
+		text := fmt.Sprintf("合成图失败 [%s]: %s", resp.Code, resp.Message)
+		for _, item := range resp.Items {
+			if item.ScreenshotResponse != nil && !item.Success {
+				text += fmt.Sprintf("\n- %s: [%s] %s", item.Device, item.Code, item.Message)
+			}
+		}
+		return &CallToolResult{
+			Content: []Content{{Type: "text", Text: text}},
+			IsError: true,
+		}, nil
+	}
+
+	imageData, err := os.ReadFile(filepath.Join(h.outputDir, resp.Filename))
+	if err != nil {
+		return &CallToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("读取合成图文件失败: %v", err),
+			}},
+			IsError: true,
+		}, nil
+	}
+
+	devices := make([]string, 0, len(resp.Items))
+	for _, item := range resp.Items {
+		devices = append(devices, fmt.Sprintf("%s (%dx%d @%gx)", item.Device, item.Width, item.Height, item.Scale))
+	}
+	resultText := fmt.Sprintf(`合成图生成成功！
+
+URL: %s
+设备: %s
+布局: %s
+尺寸: %dx%d
+格式: %s
+文件名: %s
+耗时: %d 毫秒
+
+图片已生成为 base64 编码的 %s 格式。`,
+		req.URL, strings.Join(devices, ", "), resp.Layout, resp.Width, resp.Height,
+		resp.Format, resp.Filename, resp.Total, strings.ToUpper(string(resp.Format)))
+
+	return &CallToolResult{
+		Content: []Content{
+			{
+				Type: "text",
+				Text: resultText,
+			},
+			{
+				Type:     "image",
+				Data:     base64.StdEncoding.EncodeToString(imageData),
+				MimeType: resp.Format.MimeType(),
+			},
 		},
+	}, nil
+}
+
+// handleCompareScreenshots 处理截图对比请求
+func (h *ScreenshotToolHandler) handleCompareScreenshots(ctx context.Context, arguments map[string]interface{}) (*CallToolResult, error) {
+	// 参数名与 HTTP 接口的 JSON 字段一致，直接解码
+	var req models.DiffRequest
+	data, err := json.Marshal(arguments)
+	if err == nil {
+		err = json.Unmarshal(data, &req)
+	}
+	if err != nil {
+		return &CallToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("错误：参数无效: %v", err),
+			}},
+			IsError: true,
+		}, nil
+	}
+
+	resp := h.service.Diff(ctx, req)
+	if !resp.Success {
+		return &CallToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("截图对比失败 [%s]: %s", resp.Code, resp.Message),
+			}},
+			IsError: true,
+		}, nil
 	}
 
+	imageData, err := os.ReadFile(filepath.Join(h.outputDir, resp.Filename))
+	if err != nil {
+		return &CallToolResult{
+			Content: []Content{{
+				Type: "text",
+				Text: fmt.Sprintf("读取差异图文件失败: %v", err),
+			}},
+			IsError: true,
+		}, nil
+	}
+
+	describe := func(img *models.DiffImage) string {
+		if img.URL != "" {
+			return fmt.Sprintf("%s -> %s (%dx%d)", img.URL, img.Filename, img.Width, img.Height)
+		}
+		return fmt.Sprintf("%s (%dx%d)", img.Filename, img.Width, img.Height)
+	}
+	verdict := "通过"
+	if !resp.Passed {
+		verdict = "未通过"
+	}
+	resultText := fmt.Sprintf(`截图对比完成：%s
+
+基准图片: %s
+新图片: %s
+不同像素: %d / %d (%.4f%%)
+抗锯齿像素: %d
+尺寸不同: %t
+差异图: %s
+耗时: %d 毫秒
+
+差异图中不同的像素标为红色，抗锯齿差异标为黄色，只存在于其中一张图片中的区域标为紫色。`,
+		verdict, describe(resp.Before), describe(resp.After),
+		resp.DiffPixels, resp.TotalPixels, resp.Mismatch, resp.AAPixels,
+		resp.SizeMismatch, resp.Filename, resp.Total)
+
+	return &CallToolResult{
+		Content: []Content{
+			{
+				Type: "text",
+				Text: resultText,
+			},
+			{
+				Type:     "image",
+				Data:     base64.StdEncoding.EncodeToString(imageData),
+				MimeType: models.FormatPNG.MimeType(),
+			},
+		},
+	}, nil
+}
+
+// handleGetDevicesInfo 处理获取设备信息请求
+func (h *ScreenshotToolHandler) handleGetDevicesInfo(ctx context.Context, arguments map[string]interface{}) (*CallToolResult, error) {
+	devices := h.service.Devices().List()
+
 	resultText := "支持的设备类型：\n\n"
 	for _, device := range devices {
 		resultText += fmt.Sprintf("- %s (%s)\n", device.Name, device.Type)
-		resultText += fmt.Sprintf("  尺寸: %dx%d\n", device.Width, device.Height)
+		resultText += fmt.Sprintf("  尺寸: %dx%d @%gx\n", device.Width, device.Height, device.Scale)
 		resultText += fmt.Sprintf("  移动设备: %v\n\n", device.Mobile)
 	}
 
@@ -329,12 +824,12 @@ func (h *ScreenshotToolHandler) handleGetStylesInfo(ctx context.Context, argumen
 		{
 			Type:        "glass",
 			Name:        "玻璃风格",
-			Description: "半透明玻璃边框效果，现代感十足",
+			Description: "模糊背景上的半透明玻璃卡片，现代感十足",
 		},
 		{
 			Type:        "device",
 			Name:        "设备边框",
-			Description: "模拟设备外壳边框，看起来像真实设备",
+			Description: "按设备类型套用手机、平板、笔记本或显示器外框，看起来像真实设备",
 		},
 		{
 			Type:        "floating",
@@ -357,3 +852,49 @@ func (h *ScreenshotToolHandler) handleGetStylesInfo(ctx context.Context, argumen
 		IsError: false,
 	}, nil
 }
+
+// decodeArgument 将结构化参数解码到目标类型，参数不存在时保持目标不变
+func decodeArgument(arguments map[string]interface{}, key string, v interface{}) error {
+	raw, ok := arguments[key]
+	if !ok || raw == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// formatPageInfo 格式化页面信息，以换行开头，没有页面信息时返回空字符串
+func formatPageInfo(p *models.PageInfo) string {
+	if p == nil {
+		return ""
+	}
+	text := fmt.Sprintf("\n页面状态码: %d\n最终 URL: %s", p.Status, p.FinalURL)
+	if p.Title != "" {
+		text += fmt.Sprintf("\n页面标题: %s", p.Title)
+	}
+	if p.LoadTime > 0 {
+		text += fmt.Sprintf("\n页面加载耗时: %d 毫秒", p.LoadTime)
+	}
+	return text
+}
+
+// formatTimings 格式化各阶段耗时，以换行开头，没有耗时信息时返回空字符串
+func formatTimings(t *models.Timings) string {
+	if t == nil {
+		return ""
+	}
+	return fmt.Sprintf("\n耗时: %d 毫秒（启动浏览器 %d / 导航 %d / 等待 %d / 截图 %d / 处理 %d / 保存 %d）",
+		t.Total, t.BrowserStart, t.Navigation, t.Wait, t.Capture, t.Processing, t.Save)
+}
+
+// formatQueue 格式化排队信息，以换行开头，无需排队时返回空字符串
+func formatQueue(q *models.QueueInfo) string {
+	if q == nil || q.Position == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n排队: 入队时第 %d 位，等待 %d 毫秒", q.Position, q.Wait)
+}
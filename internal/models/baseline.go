@@ -0,0 +1,118 @@
+package models
+
+import "time"
+
+// DefaultBaselineName 未指定基线集名称时使用的名称
+const DefaultBaselineName = "default"
+
+// BaselineRequest 保存基线请求：按截图参数截取页面，作为该页面的基线图片。
+// 基线由基线集名称、URL、设备和其余截图参数共同确定，相同参数再次保存时替换原基线。
+// 图片格式固定为 PNG，timeout 和 quality 不影响基线的身份。
+type BaselineRequest struct {
+	Name        string   `json:"name,omitempty"`         // 基线集名称，默认 default
+	Threshold   *float64 `json:"threshold,omitempty"`    // 对比时单个像素的颜色差异容差，0-1，默认 0.1
+	IncludeAA   bool     `json:"include_aa,omitempty"`   // 对比时是否把抗锯齿造成的差异也计为不同像素
+	MaxMismatch float64  `json:"max_mismatch,omitempty"` // 允许的不同像素百分比，0-100，不超过时判定为通过
+	ScreenshotRequest
+}
+
+// Baseline 基线：页面的身份、截图参数、对比参数和当前的基线图片
+type Baseline struct {
+	ID          string            `json:"id"` // 由基线集名称和截图参数计算，参数相同则 ID 相同
+	Name        string            `json:"name"`
+	Owner       string            `json:"owner,omitempty"` // 保存基线的客户端（API Key 名称或 IP），只有该客户端可以访问
+	URL         string            `json:"url"`
+	Device      DeviceType        `json:"device"`
+	Request     ScreenshotRequest `json:"request"` // 补全默认值后的截图参数
+	Threshold   float64           `json:"threshold"`
+	IncludeAA   bool              `json:"include_aa,omitempty"`
+	MaxMismatch float64           `json:"max_mismatch"`
+	Filename    string            `json:"filename"` // 当前基线图片的文件名
+	ImageURL    string            `json:"image_url"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`         // 最近一次保存或批准基线的时间
+	LastRun     *BaselineRun      `json:"last_run,omitempty"` // 最近一次对比的结果
+	History     []BaselineRun     `json:"history,omitempty"`  // 保存、批准和对比记录，按时间先后排列，列表接口不返回
+}
+
+// BaselineRunStatus 基线记录的类型和结果
+type BaselineRunStatus string
+
+const (
+	RunApproved BaselineRunStatus = "approved" // 保存或批准了新的基线图片
+	RunPassed   BaselineRunStatus = "passed"   // 对比通过
+	RunFailed   BaselineRunStatus = "failed"   // 不同像素超过允许的百分比
+	RunError    BaselineRunStatus = "error"    // 截图或对比失败
+)
+
+// BaselineRun 一次基线保存、批准或对比的记录
+type BaselineRun struct {
+	ID           string            `json:"id"`
+	Status       BaselineRunStatus `json:"status"`
+	Time         time.Time         `json:"time"`
+	Baseline     string            `json:"baseline"`                // 当时的基线图片文件名
+	Candidate    string            `json:"candidate,omitempty"`     // 本次截取的图片文件名，可批准为新基线
+	CandidateURL string            `json:"candidate_url,omitempty"` // 本次截取的图片地址
+	Diff         string            `json:"diff,omitempty"`          // 差异图文件名
+	DiffURL      string            `json:"diff_url,omitempty"`      // 差异图地址
+	Mismatch     float64           `json:"mismatch,omitempty"`      // 不同像素所占的百分比
+	DiffPixels   int               `json:"diff_pixels,omitempty"`
+	SizeMismatch bool              `json:"size_mismatch,omitempty"`
+	Code         ErrorCode         `json:"code,omitempty"` // 截图或对比失败时的错误码
+	Message      string            `json:"message,omitempty"`
+}
+
+// BaselineResponse 保存、查询或批准基线的响应
+type BaselineResponse struct {
+	Success  bool      `json:"success"`
+	Message  string    `json:"message,omitempty"`
+	Code     ErrorCode `json:"code,omitempty"`
+	Baseline *Baseline `json:"baseline,omitempty"`
+}
+
+// BaselineCompareResponse 重新截图并与基线对比的响应
+type BaselineCompareResponse struct {
+	Success  bool         `json:"success"` // 截图和对比是否完成，是否通过见 passed
+	Message  string       `json:"message,omitempty"`
+	Code     ErrorCode    `json:"code,omitempty"`
+	Passed   bool         `json:"passed"`
+	Baseline *Baseline    `json:"baseline,omitempty"` // 不包含历史记录
+	Run      *BaselineRun `json:"run,omitempty"`
+}
+
+// BaselineApproveRequest 批准基线请求，RunID 为空时批准最近一次截图成功的对比
+type BaselineApproveRequest struct {
+	RunID string `json:"run_id,omitempty"`
+}
+
+// BaselineCheckRequest 批量对比请求，供 CI 一次检查一个基线集中的所有页面
+type BaselineCheckRequest struct {
+	Name  string `json:"name,omitempty"`  // 基线集名称，为空时检查所有基线
+	Async bool   `json:"async,omitempty"` // 是否以异步任务执行
+}
+
+// 批量对比的整体结果
+const (
+	CheckPassed = "passed" // 全部页面通过
+	CheckFailed = "failed" // 有页面未通过或对比失败
+)
+
+// BaselineCheckResponse 批量对比响应
+type BaselineCheckResponse struct {
+	Status  string              `json:"status"` // passed 或 failed
+	Message string              `json:"message,omitempty"`
+	Total   int                 `json:"total"`
+	Passed  int                 `json:"passed"`
+	Failed  int                 `json:"failed"` // 不同像素超过允许的百分比
+	Errors  int                 `json:"errors"` // 截图或对比失败
+	Pages   []BaselineCheckItem `json:"pages"`
+}
+
+// BaselineCheckItem 批量对比中单个页面的结果
+type BaselineCheckItem struct {
+	BaselineID string     `json:"baseline_id"`
+	Name       string     `json:"name"`
+	URL        string     `json:"url"`
+	Device     DeviceType `json:"device"`
+	*BaselineRun
+}
@@ -0,0 +1,36 @@
+package models
+
+// BatchRequest 批量截图请求，对 URLs × Devices × Styles 的每个组合各截一张图。
+// 内嵌的 ScreenshotRequest 为所有组合共用的截图参数，其中 URL 被忽略，
+// Devices、Styles 为空时分别使用其中的 Device、Style。
+type BatchRequest struct {
+	URLs    []string      `json:"urls"`
+	Devices []DeviceType  `json:"devices,omitempty"`
+	Styles  []MockupStyle `json:"styles,omitempty"`
+	Async   bool          `json:"async,omitempty"` // 是否以异步任务执行
+	ScreenshotRequest
+}
+
+// 批量截图的整体状态
+const (
+	BatchSuccess = "success" // 全部成功
+	BatchPartial = "partial" // 部分失败
+	BatchFailed  = "failed"  // 全部失败
+)
+
+// BatchResponse 批量截图响应
+type BatchResponse struct {
+	Status    string      `json:"status"` // success, partial 或 failed
+	Total     int         `json:"total"`
+	Succeeded int         `json:"succeeded"`
+	Failed    int         `json:"failed"`
+	Items     []BatchItem `json:"items"`
+}
+
+// BatchItem 批量截图中单个组合的结果
+type BatchItem struct {
+	URL    string      `json:"url"`
+	Device DeviceType  `json:"device"`
+	Style  MockupStyle `json:"style"`
+	*ScreenshotResponse
+}
@@ -0,0 +1,49 @@
+package models
+
+// CompositeLayout 多设备合成图的布局
+type CompositeLayout string
+
+const (
+	LayoutShowcase CompositeLayout = "showcase" // 以最大的设备为中心，其余设备从两侧叠放在前方
+	LayoutGrid     CompositeLayout = "grid"     // 等大的网格，每个设备缩放到填满单元格
+	LayoutRow      CompositeLayout = "row"      // 按真实比例从大到小排成一行，底部对齐
+)
+
+// 多设备合成图的取值范围和默认值
+const (
+	MaxCompositeDevices   = 6
+	DefaultCompositeSpace = 60   // 默认间距(像素)
+	MaxCompositeSpace     = 500  // 最大间距(像素)
+	MaxCanvasWidth        = 8000 // 最大画布宽度(像素)
+	DefaultCanvasWidth    = 3200 // 未指定画布宽度时，按真实比例排版后超过该宽度则缩小
+)
+
+// DefaultCompositeDevices 未指定设备时使用的设备
+var DefaultCompositeDevices = []DeviceType{DeviceDesktop, DeviceLaptop, DeviceTablet, DeviceMobile}
+
+// CompositeRequest 多设备合成图请求：在多个设备上截取同一个 URL，套用设备外框后合成到一张图中。
+// 内嵌的 ScreenshotRequest 为各设备共用的截图参数，其中 Device、Style 被忽略，
+// 不支持全页、元素和区域截图，也不支持自定义视口。
+type CompositeRequest struct {
+	Devices     []DeviceType    `json:"devices,omitempty"`      // 为空时使用桌面、笔记本、平板和手机
+	Layout      CompositeLayout `json:"layout,omitempty"`       // 为空时使用 showcase
+	Spacing     *int            `json:"spacing,omitempty"`      // 设备之间及画布四周的间距(像素)
+	Columns     int             `json:"columns,omitempty"`      // grid 布局的列数，0 表示自动
+	CanvasWidth int             `json:"canvas_width,omitempty"` // 输出图片宽度(像素)，0 表示自动
+	ScreenshotRequest
+}
+
+// CompositeResponse 多设备合成图响应
+type CompositeResponse struct {
+	Success  bool            `json:"success"`
+	Message  string          `json:"message,omitempty"`
+	Code     ErrorCode       `json:"code,omitempty"` // 失败时的错误码，某个设备截图失败时为该设备的错误码
+	ImageURL string          `json:"image_url,omitempty"`
+	Filename string          `json:"filename,omitempty"`
+	Format   ImageFormat     `json:"format,omitempty"`
+	Layout   CompositeLayout `json:"layout,omitempty"`
+	Width    int             `json:"width,omitempty"`  // 合成图宽度(像素)
+	Height   int             `json:"height,omitempty"` // 合成图高度(像素)
+	Items    []BatchItem     `json:"items,omitempty"`  // 各设备的截图结果，不包含单独的图片
+	Total    int64           `json:"total,omitempty"`  // 总耗时(毫秒)
+}
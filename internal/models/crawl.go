@@ -0,0 +1,43 @@
+package models
+
+// 站点爬取的取值范围和默认值，页面数上限由服务配置决定
+const (
+	DefaultCrawlDepth = 2
+	MaxCrawlDepth     = 5
+	DefaultCrawlPages = 20
+)
+
+// CrawlRequest 站点爬取请求：从起始页面（或 sitemap 中列出的页面）出发，沿同源链接发现页面并逐个截图。
+// 内嵌的 ScreenshotRequest 为所有页面共用的截图参数，其中 URL 为起始页面或 sitemap 的地址。
+type CrawlRequest struct {
+	Sitemap  bool     `json:"sitemap,omitempty"`   // URL 是否为 sitemap，路径以 .xml 结尾时自动识别
+	MaxDepth *int     `json:"max_depth,omitempty"` // 从起始页面出发跟随链接的最大层数，0 表示只截起始页面
+	MaxPages int      `json:"max_pages,omitempty"` // 最多截图的页面数
+	Include  []string `json:"include,omitempty"`   // 正则表达式，非空时只跟随匹配其中之一的链接
+	Exclude  []string `json:"exclude,omitempty"`   // 正则表达式，不跟随匹配其中之一的链接，优先于 Include
+	ScreenshotRequest
+}
+
+// CrawlResponse 站点爬取结果
+type CrawlResponse struct {
+	Status     string      `json:"status"` // success, partial 或 failed
+	Message    string      `json:"message,omitempty"`
+	Code       ErrorCode   `json:"code,omitempty"` // 无法开始爬取（如读取 sitemap 失败）时的错误码
+	Root       string      `json:"root"`
+	Total      int         `json:"total"`
+	Succeeded  int         `json:"succeeded"`
+	Failed     int         `json:"failed"`
+	Discovered int         `json:"discovered"`          // 发现的同源页面数，超出 max_pages 的页面不截图
+	Truncated  bool        `json:"truncated,omitempty"` // 是否因达到 max_pages 而停止
+	IndexURL   string      `json:"index_url,omitempty"` // HTML 图集
+	IndexJSON  string      `json:"index_json,omitempty"`
+	Pages      []CrawlPage `json:"pages"`
+}
+
+// CrawlPage 单个页面的截图结果
+type CrawlPage struct {
+	URL    string `json:"url"`
+	Depth  int    `json:"depth"`
+	Parent string `json:"parent,omitempty"` // 发现该页面的页面，起始页面和 sitemap 中的页面为空
+	*ScreenshotResponse
+}
@@ -0,0 +1,204 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 移动设备默认使用的 User-Agent
+const (
+	UserAgentIPhone = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1"
+	UserAgentIPad   = "Mozilla/5.0 (iPad; CPU OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1"
+)
+
+// builtinDevices 内置设备预设
+var builtinDevices = []DeviceConfig{
+	{
+		Type:   DeviceDesktop,
+		Name:   "桌面",
+		Width:  1920,
+		Height: 1080,
+		Scale:  1.0,
+		Mobile: false,
+		Frame:  "monitor",
+	},
+	{
+		Type:   DeviceLaptop,
+		Name:   "笔记本",
+		Width:  1440,
+		Height: 900,
+		Scale:  1.0,
+		Mobile: false,
+		Frame:  "laptop",
+	},
+	{
+		Type:      DeviceTablet,
+		Name:      "平板",
+		Width:     768,
+		Height:    1024,
+		Scale:     2.0,
+		Mobile:    true,
+		Touch:     true,
+		UserAgent: UserAgentIPad,
+		Platform:  "iPad",
+		Frame:     "tablet",
+	},
+	{
+		Type:      DeviceMobile,
+		Name:      "手机",
+		Width:     375,
+		Height:    812,
+		Scale:     2.0,
+		Mobile:    true,
+		Touch:     true,
+		UserAgent: UserAgentIPhone,
+		Platform:  "iPhone",
+		Frame:     "phone",
+	},
+}
+
+// DeviceRegistry 设备预设注册表
+type DeviceRegistry struct {
+	mu      sync.RWMutex
+	devices map[DeviceType]DeviceConfig
+	order   []DeviceType
+}
+
+// deviceFile 设备预设文件格式
+type deviceFile struct {
+	Devices []DeviceConfig `json:"devices" yaml:"devices"`
+}
+
+// NewDeviceRegistry 创建只包含内置预设的设备注册表
+func NewDeviceRegistry() *DeviceRegistry {
+	r := &DeviceRegistry{
+		devices: make(map[DeviceType]DeviceConfig),
+	}
+	for _, device := range builtinDevices {
+		r.Register(device)
+	}
+	return r
+}
+
+// LoadDeviceRegistry 创建设备注册表并从 JSON 或 YAML 文件加载自定义预设，
+// 文件中与内置预设同名的设备会覆盖内置预设。path 为空时只包含内置预设。
+func LoadDeviceRegistry(path string) (*DeviceRegistry, error) {
+	r := NewDeviceRegistry()
+	if path == "" {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取设备预设文件失败: %w", err)
+	}
+
+	var file deviceFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	default:
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析设备预设文件失败: %w", err)
+	}
+
+	for _, device := range file.Devices {
+		if err := device.Validate(); err != nil {
+			return nil, fmt.Errorf("设备预设 %q 无效: %w", device.Type, err)
+		}
+		r.Register(device)
+	}
+	return r, nil
+}
+
+// Register 注册（或覆盖）设备预设
+func (r *DeviceRegistry) Register(device DeviceConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if device.Scale == 0 {
+		device.Scale = 1.0
+	}
+	if device.Name == "" {
+		device.Name = string(device.Type)
+	}
+	if _, exists := r.devices[device.Type]; !exists {
+		r.order = append(r.order, device.Type)
+	}
+	r.devices[device.Type] = device
+}
+
+// Get 获取设备预设，设备不存在时返回错误
+func (r *DeviceRegistry) Get(deviceType DeviceType) (DeviceConfig, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	device, exists := r.devices[deviceType]
+	if !exists {
+		return DeviceConfig{}, fmt.Errorf("不支持的设备类型: %s", deviceType)
+	}
+	return device, nil
+}
+
+// List 按注册顺序返回所有设备预设
+func (r *DeviceRegistry) List() []DeviceConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	devices := make([]DeviceConfig, 0, len(r.order))
+	for _, deviceType := range r.order {
+		devices = append(devices, r.devices[deviceType])
+	}
+	return devices
+}
+
+// Types 按注册顺序返回所有设备类型
+func (r *DeviceRegistry) Types() []string {
+	devices := r.List()
+	types := make([]string, len(devices))
+	for i, device := range devices {
+		types[i] = string(device.Type)
+	}
+	return types
+}
+
+// Emulation 返回模拟设备时使用的 User-Agent 和 navigator.platform。
+// 未配置 User-Agent 的移动设备按宽度使用 iPhone 或 iPad 的默认值，桌面设备保持浏览器默认值（返回空字符串）。
+func (d DeviceConfig) Emulation() (userAgent, platform string) {
+	userAgent, platform = d.UserAgent, d.Platform
+	if userAgent == "" && d.Mobile {
+		if min(d.Width, d.Height) < 600 {
+			userAgent, platform = UserAgentIPhone, "iPhone"
+		} else {
+			userAgent, platform = UserAgentIPad, "iPad"
+		}
+	}
+	return userAgent, platform
+}
+
+// Landscape 判断设备是否为横屏
+func (d DeviceConfig) Landscape() bool {
+	return d.Width > d.Height
+}
+
+// Validate 校验设备预设
+func (d DeviceConfig) Validate() error {
+	if d.Type == "" {
+		return fmt.Errorf("设备类型不能为空")
+	}
+	if d.Width <= 0 || d.Height <= 0 {
+		return fmt.Errorf("宽高必须为正数")
+	}
+	if d.Scale < 0 {
+		return fmt.Errorf("缩放比例不能为负数")
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package models
+
+// DefaultDiffThreshold 截图对比默认的单个像素颜色差异容差
+const DefaultDiffThreshold = 0.1
+
+// DiffSource 参与对比的一张图片：现场截取的 URL，或输出目录中已保存的截图文件名，二者只能指定一个
+type DiffSource struct {
+	URL      string `json:"url,omitempty"`
+	Filename string `json:"filename,omitempty"`
+}
+
+// DiffRequest 截图对比请求，逐像素比较两张图片并生成差异图。
+// 内嵌的 ScreenshotRequest 为截取 URL 时共用的截图参数，其中 URL 被忽略，图片格式固定为 PNG。
+type DiffRequest struct {
+	Before      DiffSource `json:"before"`
+	After       DiffSource `json:"after"`
+	Threshold   *float64   `json:"threshold,omitempty"`    // 单个像素的颜色差异容差，0-1，默认 0.1
+	IncludeAA   bool       `json:"include_aa,omitempty"`   // 是否把抗锯齿造成的差异也计为不同像素
+	MaxMismatch float64    `json:"max_mismatch,omitempty"` // 允许的不同像素百分比，0-100，不超过时 passed 为 true
+	ScreenshotRequest
+}
+
+// DiffResponse 截图对比响应
+type DiffResponse struct {
+	Success      bool       `json:"success"`
+	Message      string     `json:"message,omitempty"`
+	Code         ErrorCode  `json:"code,omitempty"`
+	Passed       bool       `json:"passed"`                       // 不同像素百分比是否不超过 max_mismatch
+	Mismatch     float64    `json:"mismatch"`                     // 不同像素所占的百分比
+	DiffPixels   int        `json:"diff_pixels"`                  // 不同的像素数
+	AAPixels     int        `json:"antialiased_pixels,omitempty"` // 因抗锯齿而忽略的像素数
+	TotalPixels  int        `json:"total_pixels,omitempty"`       // 比较的像素总数
+	SizeMismatch bool       `json:"size_mismatch,omitempty"`      // 两张图片尺寸是否不同
+	ImageURL     string     `json:"image_url,omitempty"`          // 差异图地址
+	Filename     string     `json:"filename,omitempty"`           // 差异图文件名
+	Width        int        `json:"width,omitempty"`              // 差异图宽度(像素)
+	Height       int        `json:"height,omitempty"`             // 差异图高度(像素)
+	Before       *DiffImage `json:"before,omitempty"`
+	After        *DiffImage `json:"after,omitempty"`
+	Total        int64      `json:"total,omitempty"` // 总耗时(毫秒)
+}
+
+// DiffImage 参与对比的一张图片的信息
+type DiffImage struct {
+	URL      string `json:"url,omitempty"` // 截取的页面地址，使用已保存的截图时为空
+	ImageURL string `json:"image_url"`
+	Filename string `json:"filename"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
@@ -20,12 +20,71 @@ const (
 	StyleFloating MockupStyle = "floating" // 浮动阴影
 )
 
+// ImageFormat 表示输出图片格式
+type ImageFormat string
+
+const (
+	FormatPNG  ImageFormat = "png"
+	FormatJPEG ImageFormat = "jpeg"
+	FormatWebP ImageFormat = "webp"
+)
+
+// Extension 返回图片格式对应的文件扩展名
+func (f ImageFormat) Extension() string {
+	switch f {
+	case FormatJPEG:
+		return ".jpg"
+	case FormatWebP:
+		return ".webp"
+	default:
+		return ".png"
+	}
+}
+
+// MimeType 返回图片格式对应的 MIME 类型
+func (f ImageFormat) MimeType() string {
+	switch f {
+	case FormatJPEG:
+		return "image/jpeg"
+	case FormatWebP:
+		return "image/webp"
+	default:
+		return "image/png"
+	}
+}
+
+// Lossy 表示该格式是否为有损压缩（Quality 仅对有损格式生效）
+func (f ImageFormat) Lossy() bool {
+	return f == FormatJPEG || f == FormatWebP
+}
+
+// FormatFromExtension 根据文件扩展名推断图片格式
+func FormatFromExtension(ext string) (ImageFormat, bool) {
+	switch ext {
+	case ".png":
+		return FormatPNG, true
+	case ".jpg", ".jpeg":
+		return FormatJPEG, true
+	case ".webp":
+		return FormatWebP, true
+	}
+	return "", false
+}
+
 // DeviceConfig 设备配置
 type DeviceConfig struct {
-	Width  int64
-	Height int64
-	Scale  float64
-	Mobile bool
+	Type      DeviceType `json:"type" yaml:"type"`
+	Name      string     `json:"name" yaml:"name"`                                 // 显示名称
+	Width     int64      `json:"width" yaml:"width"`                               // 视口宽度(CSS 像素)
+	Height    int64      `json:"height" yaml:"height"`                             // 视口高度(CSS 像素)
+	Scale     float64    `json:"scale" yaml:"scale"`                               // 设备像素比
+	Mobile    bool       `json:"mobile" yaml:"mobile"`                             // 是否模拟移动设备
+	Touch     bool       `json:"touch" yaml:"touch"`                               // 是否启用触摸
+	UserAgent string     `json:"user_agent,omitempty" yaml:"user_agent,omitempty"` // 自定义 User-Agent
+	Platform  string     `json:"platform,omitempty" yaml:"platform,omitempty"`     // navigator.platform
+	Frame     string     `json:"frame,omitempty" yaml:"frame,omitempty"`           // device 样式使用的外框名称
+	// InferFrame 请求覆盖了视口尺寸或移动模式，预设的外框不再适用，按尺寸重新推断外框
+	InferFrame bool `json:"-" yaml:"-"`
 }
 
 // ScreenshotRequest 截图请求
@@ -33,52 +92,231 @@ type ScreenshotRequest struct {
 	URL        string      `json:"url"`
 	Device     DeviceType  `json:"device"`
 	Style      MockupStyle `json:"style"`
-	Delay      int         `json:"delay"`      // 延迟时间(毫秒)
-	FullPage   bool        `json:"full_page"`  // 是否全页截图
-	Quality    int         `json:"quality"`    // 图片质量 (1-100)
-	Background string      `json:"background"` // 背景颜色
+	Delay      int         `json:"delay"`            // 等待条件满足后的额外稳定时间(毫秒)
+	FullPage   bool        `json:"full_page"`        // 是否全页截图
+	Quality    int         `json:"quality"`          // 图片质量 (1-100)，仅对 jpeg/webp 生效
+	Format     ImageFormat `json:"format"`           // 输出格式: png, jpeg, webp
+	Background string      `json:"background"`       // 背景颜色
+	Shadow     *ShadowSpec `json:"shadow,omitempty"` // 阴影参数（floating 样式）
+	Glass      *GlassSpec  `json:"glass,omitempty"`  // 玻璃效果参数（glass 样式）
+
+	// 截图前依次等待的条件，为空时等待 load 事件
+	Wait []WaitCondition `json:"wait,omitempty"`
+
+	// 可接受的页面 HTTP 状态码范围，为空时接受 200-399
+	AcceptStatus *StatusRange `json:"accept_status,omitempty"`
+
+	// 整个请求的超时时间(毫秒)，0 表示使用服务默认值，不能超过服务允许的最大值
+	Timeout int `json:"timeout,omitempty"`
+
+	// 截取局部区域，Selector 与 Clip 只能指定一个，指定后忽略 FullPage
+	Selector string    `json:"selector,omitempty"` // 只截取匹配该 CSS 选择器的第一个元素
+	Padding  int       `json:"padding,omitempty"`  // 元素四周额外保留的边距(CSS 像素)
+	Clip     *ClipRect `json:"clip,omitempty"`     // 截取页面中的指定矩形区域
+
+	// 自定义视口，指定后覆盖设备预设中的对应值
+	Width             int64   `json:"width,omitempty"`               // 视口宽度(CSS 像素)
+	Height            int64   `json:"height,omitempty"`              // 视口高度(CSS 像素)
+	DeviceScaleFactor float64 `json:"device_scale_factor,omitempty"` // 设备像素比
+	Mobile            *bool   `json:"mobile,omitempty"`              // 是否模拟移动设备
+	Orientation       string  `json:"orientation,omitempty"`         // 屏幕方向: portrait, landscape
+	UserAgent         string  `json:"user_agent,omitempty"`          // 自定义 User-Agent，覆盖设备预设
+}
+
+// 屏幕方向
+const (
+	OrientationPortrait  = "portrait"
+	OrientationLandscape = "landscape"
+)
+
+// 自定义视口的取值范围
+const (
+	MinViewportSize   = 100
+	MaxViewportWidth  = 7680
+	MaxViewportHeight = 7680
+	MinScaleFactor    = 0.5
+	MaxScaleFactor    = 4.0
+)
+
+// HasViewportOverride 判断请求是否指定了自定义视口
+func (r ScreenshotRequest) HasViewportOverride() bool {
+	return r.Width > 0 || r.Height > 0 || r.DeviceScaleFactor > 0 || r.Mobile != nil || r.Orientation != ""
+}
+
+// ApplyOverrides 用请求中的自定义视口、屏幕方向和 User-Agent 覆盖设备预设。
+// 宽高或移动模式被覆盖后，预设的外框不再适用，Frame 会被清空并设置 InferFrame 以便按尺寸重新推断；
+// 移动模式被覆盖后，预设的 User-Agent 和平台同样被清空，改用对应模式的默认值。
+func (r ScreenshotRequest) ApplyOverrides(device DeviceConfig) DeviceConfig {
+	if r.Width > 0 && r.Width != device.Width {
+		device.Width = r.Width
+		device.Frame = ""
+		device.InferFrame = true
+	}
+	if r.Height > 0 && r.Height != device.Height {
+		device.Height = r.Height
+		device.Frame = ""
+		device.InferFrame = true
+	}
+	if r.DeviceScaleFactor > 0 {
+		device.Scale = r.DeviceScaleFactor
+	}
+	if r.Mobile != nil && *r.Mobile != device.Mobile {
+		device.Mobile = *r.Mobile
+		device.Touch = *r.Mobile
+		device.UserAgent = ""
+		device.Platform = ""
+		device.Frame = ""
+		device.InferFrame = true
+	}
+	if (r.Orientation == OrientationLandscape && device.Height > device.Width) ||
+		(r.Orientation == OrientationPortrait && device.Width > device.Height) {
+		device.Width, device.Height = device.Height, device.Width
+	}
+	if r.UserAgent != "" {
+		device.UserAgent = r.UserAgent
+	}
+	return device
+}
+
+// StatusRange HTTP 状态码范围（包含两端）
+type StatusRange struct {
+	Min int64 `json:"min"`
+	Max int64 `json:"max"`
+}
+
+// DefaultAcceptStatus 默认可接受的页面状态码范围
+var DefaultAcceptStatus = StatusRange{Min: 200, Max: 399}
+
+// Contains 判断状态码是否在范围内
+func (r StatusRange) Contains(status int64) bool {
+	return status >= r.Min && status <= r.Max
+}
+
+// ClipRect 页面中的矩形区域，以文档左上角为原点(CSS 像素)
+type ClipRect struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// 局部截图的取值范围(CSS 像素)
+const (
+	MaxElementPadding = 500
+	MaxClipSize       = 16384
+)
+
+// WaitType 等待条件类型
+type WaitType string
+
+const (
+	WaitLoad        WaitType = "load"         // 等待 load 事件
+	WaitNetworkIdle WaitType = "network_idle" // 等待网络空闲
+	WaitSelector    WaitType = "selector"     // 等待 CSS 选择器匹配的元素可见
+	WaitExpression  WaitType = "expression"   // 等待 JS 表达式返回真值
+	WaitFonts       WaitType = "fonts"        // 等待 document.fonts.ready
+)
+
+// 等待条件的默认值和上限(毫秒)
+const (
+	DefaultWaitTimeout = 10000
+	MaxWaitTimeout     = 60000
+	DefaultNetworkIdle = 500
+	MaxNetworkIdleTime = 10000
+)
+
+// WaitCondition 截图前的等待条件
+type WaitCondition struct {
+	Type     WaitType `json:"type"`
+	Value    string   `json:"value,omitempty"`     // CSS 选择器（selector）或 JS 表达式（expression）
+	IdleTime int      `json:"idle_time,omitempty"` // 无请求持续多久视为网络空闲(毫秒)，仅 network_idle 使用
+	Timeout  int      `json:"timeout,omitempty"`   // 超时时间(毫秒)
+}
+
+// MaxBackgroundImageSize 玻璃效果背景图片（base64 或 data URI）的最大长度(字节)
+const MaxBackgroundImageSize = 10 << 20
+
+// GlassSpec 玻璃效果参数，未指定的字段使用默认值
+type GlassSpec struct {
+	Blur            *int     `json:"blur,omitempty"`             // 背景模糊半径(像素)，默认 40，0 表示不模糊
+	Opacity         *float64 `json:"opacity,omitempty"`          // 玻璃卡片的不透明度 (0-1)，默认 0.25，0 表示完全透明
+	Highlight       *bool    `json:"highlight,omitempty"`        // 是否绘制高光渐变，默认开启
+	BackgroundImage string   `json:"background_image,omitempty"` // 背景图片（base64 或 data URI），为空时使用模糊放大的截图
+}
+
+// MaxShadowOffset 阴影偏移和扩展距离的最大绝对值(像素)
+const MaxShadowOffset = 500
+
+// ShadowSpec 阴影参数，未指定的字段使用默认值，显式传 0 时不模糊、不偏移或不绘制阴影
+type ShadowSpec struct {
+	Blur    *int     `json:"blur,omitempty"`     // 模糊半径(像素)，默认 40
+	OffsetX *int     `json:"offset_x,omitempty"` // 水平偏移(像素)，默认 0
+	OffsetY *int     `json:"offset_y,omitempty"` // 垂直偏移(像素)，默认 20
+	Spread  *int     `json:"spread,omitempty"`   // 扩展距离(像素)，默认 0
+	Color   string   `json:"color,omitempty"`    // 阴影颜色
+	Opacity *float64 `json:"opacity,omitempty"`  // 不透明度 (0-1)，默认 0.35
 }
 
 // ScreenshotResponse 截图响应
 type ScreenshotResponse struct {
-	Success  bool   `json:"success"`
-	Message  string `json:"message,omitempty"`
-	ImageURL string `json:"image_url,omitempty"`
-	Filename string `json:"filename,omitempty"`
-}
-
-// GetDeviceConfig 获取设备配置
-func GetDeviceConfig(deviceType DeviceType) DeviceConfig {
-	configs := map[DeviceType]DeviceConfig{
-		DeviceDesktop: {
-			Width:  1920,
-			Height: 1080,
-			Scale:  1.0,
-			Mobile: false,
-		},
-		DeviceLaptop: {
-			Width:  1440,
-			Height: 900,
-			Scale:  1.0,
-			Mobile: false,
-		},
-		DeviceTablet: {
-			Width:  768,
-			Height: 1024,
-			Scale:  2.0,
-			Mobile: true,
-		},
-		DeviceMobile: {
-			Width:  375,
-			Height: 812,
-			Scale:  2.0,
-			Mobile: true,
-		},
-	}
+	Success  bool        `json:"success"`
+	Message  string      `json:"message,omitempty"`
+	ImageURL string      `json:"image_url,omitempty"`
+	Filename string      `json:"filename,omitempty"`
+	Style    MockupStyle `json:"style,omitempty"`  // 实际应用的样式
+	Format   ImageFormat `json:"format,omitempty"` // 实际输出的图片格式
+	Width    int64       `json:"width,omitempty"`  // 实际使用的视口宽度
+	Height   int64       `json:"height,omitempty"` // 实际使用的视口高度
+	Scale    float64     `json:"device_scale_factor,omitempty"`
+	Page     *PageInfo   `json:"page,omitempty"`    // 页面信息，导航失败时可能为空
+	Code     ErrorCode   `json:"code,omitempty"`    // 失败时的错误码
+	Timings  *Timings    `json:"timings,omitempty"` // 各阶段耗时，失败时只包含已完成的阶段
+	Queue    *QueueInfo  `json:"queue,omitempty"`   // 排队信息，请求被拒绝时为空
+}
 
-	config, exists := configs[deviceType]
-	if !exists {
-		return configs[DeviceDesktop]
-	}
-	return config
+// ErrorCode 截图失败的错误码，取值稳定，供客户端判断是否重试
+type ErrorCode string
+
+const (
+	CodeInvalidURL         ErrorCode = "invalid_url"         // URL 为空或格式无效
+	CodeInvalidRequest     ErrorCode = "invalid_request"     // 其他请求参数无效
+	CodeNotFound           ErrorCode = "not_found"           // 请求的截图文件不存在
+	CodeURLBlocked         ErrorCode = "url_blocked"         // URL 或重定向、子资源违反访问策略
+	CodeDNSFailure         ErrorCode = "dns_failure"         // 域名解析失败
+	CodeHTTPErrorStatus    ErrorCode = "http_error_status"   // 页面返回错误的 HTTP 状态码
+	CodeNavigationFailed   ErrorCode = "navigation_failed"   // 其他网络错误，如连接被拒绝
+	CodeNavigationTimeout  ErrorCode = "navigation_timeout"  // 页面导航或等待页面就绪超时
+	CodeBrowserUnavailable ErrorCode = "browser_unavailable" // 无法获取或启动浏览器
+	CodeRenderFailed       ErrorCode = "render_failed"       // 截图或图片处理失败
+	CodeStorageFailed      ErrorCode = "storage_failed"      // 保存文件失败
+	CodeUnauthorized       ErrorCode = "unauthorized"        // 缺少或无效的 API Key
+	CodeForbidden          ErrorCode = "forbidden"           // API Key 没有访问该接口的权限
+	CodeRateLimited        ErrorCode = "rate_limited"        // 请求过于频繁
+	CodeConcurrencyLimit   ErrorCode = "concurrency_limit"   // 同时进行的截图数量超出上限或队列已满
+	CodeQueueTimeout       ErrorCode = "queue_timeout"       // 排队等待期间请求超时
+)
+
+// PageInfo 截图页面的信息
+type PageInfo struct {
+	Status   int64  `json:"status,omitempty"`    // 主文档的 HTTP 状态码，没有 HTTP 响应（如 data: URL）时为 0
+	FinalURL string `json:"final_url,omitempty"` // 跟随重定向后截图时的页面地址
+	Title    string `json:"title,omitempty"`     // 页面标题
+	LoadTime int64  `json:"load_time,omitempty"` // 从开始导航到 load 事件的耗时(毫秒)，截图前未触发 load 时为 0
+}
+
+// QueueInfo 截图请求的排队信息
+type QueueInfo struct {
+	Position int   `json:"position"` // 入队时的排队位置，1 表示下一个执行，0 表示无需排队
+	Wait     int64 `json:"wait"`     // 排队等待时间(毫秒)
+}
+
+// Timings 截图各阶段耗时(毫秒)
+type Timings struct {
+	BrowserStart int64 `json:"browser_start"` // 获取浏览器并打开标签页
+	Navigation   int64 `json:"navigation"`    // 设备模拟和页面导航
+	Wait         int64 `json:"wait"`          // 等待页面就绪（含 delay）
+	Capture      int64 `json:"capture"`       // 截图
+	Processing   int64 `json:"processing"`    // 套用样式和编码
+	Save         int64 `json:"save"`          // 保存文件
+	Total        int64 `json:"total"`         // 总耗时
 }
@@ -0,0 +1,122 @@
+// Package ratelimit 按客户端（API Key 或 IP）限制请求速率的令牌桶
+package ratelimit
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// sweepInterval 清理已回满的令牌桶的间隔
+const sweepInterval = time.Minute
+
+// Config 限流配置
+type Config struct {
+	PerMinute float64 // 每分钟补充的令牌数，0 表示不限流
+	Burst     int     // 令牌桶容量，即允许的突发请求数
+}
+
+// ConfigFromEnv 从环境变量读取限流配置
+func ConfigFromEnv() Config {
+	config := Config{PerMinute: 60, Burst: 10}
+	if value := os.Getenv("RATE_LIMIT_PER_MINUTE"); value != "" {
+		if n, err := strconv.ParseFloat(value, 64); err == nil && n >= 0 {
+			config.PerMinute = n
+		}
+	}
+	if value := os.Getenv("RATE_LIMIT_BURST"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			config.Burst = n
+		}
+	}
+	return config
+}
+
+// Result 一次限流检查的结果
+type Result struct {
+	Allowed    bool
+	Limit      int           // 令牌桶容量
+	Remaining  int           // 剩余令牌数
+	RetryAfter time.Duration // 被拒绝时，距下一个令牌可用的时间
+	Reset      time.Duration // 距令牌桶回满的时间
+}
+
+// bucket 单个客户端的令牌桶
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Limiter 按客户端划分的令牌桶限流器
+type Limiter struct {
+	rate  float64 // 每秒补充的令牌数
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// New 创建限流器，config.PerMinute 为 0 时返回 nil，表示不限流
+func New(config Config) *Limiter {
+	if config.PerMinute <= 0 {
+		return nil
+	}
+	if config.Burst <= 0 {
+		config.Burst = 1
+	}
+	return &Limiter{
+		rate:      config.PerMinute / 60,
+		burst:     float64(config.Burst),
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow 为客户端消耗一个令牌
+func (l *Limiter) Allow(client string) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, updated: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+	b.updated = now
+
+	result := Result{Limit: int(l.burst)}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = l.duration(1 - b.tokens)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = l.duration(l.burst - b.tokens)
+	return result
+}
+
+// duration 返回补充指定数量令牌所需的时间
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// sweep 定期删除已经回满的令牌桶，避免客户端数量无限增长
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+}
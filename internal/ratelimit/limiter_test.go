@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewDisabled(t *testing.T) {
+	if l := New(Config{PerMinute: 0, Burst: 10}); l != nil {
+		t.Fatalf("PerMinute 为 0 时应返回 nil")
+	}
+}
+
+func TestAllowBurst(t *testing.T) {
+	tests := []struct {
+		name    string
+		burst   int
+		calls   int
+		allowed int
+	}{
+		{"在容量之内", 3, 2, 2},
+		{"恰好用完", 3, 3, 3},
+		{"超出容量", 3, 5, 3},
+		{"容量为 0 时按 1 处理", 0, 2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 每分钟 1 个令牌，测试期间不会补充
+			l := New(Config{PerMinute: 1, Burst: tt.burst})
+			allowed := 0
+			for i := 0; i < tt.calls; i++ {
+				if l.Allow("a").Allowed {
+					allowed++
+				}
+			}
+			if allowed != tt.allowed {
+				t.Fatalf("allowed = %d, want %d", allowed, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestAllowResult(t *testing.T) {
+	l := New(Config{PerMinute: 60, Burst: 2})
+
+	first := l.Allow("a")
+	if !first.Allowed || first.Limit != 2 || first.Remaining != 1 {
+		t.Fatalf("first = %+v", first)
+	}
+	l.Allow("a")
+	denied := l.Allow("a")
+	if denied.Allowed {
+		t.Fatalf("令牌耗尽后应拒绝")
+	}
+	if denied.Remaining != 0 {
+		t.Fatalf("Remaining = %d, want 0", denied.Remaining)
+	}
+	// 每秒补充 1 个令牌，下一个令牌最多 1 秒后可用，回满最多 2 秒
+	if denied.RetryAfter <= 0 || denied.RetryAfter > time.Second {
+		t.Fatalf("RetryAfter = %v", denied.RetryAfter)
+	}
+	if denied.Reset < denied.RetryAfter || denied.Reset > 2*time.Second {
+		t.Fatalf("Reset = %v", denied.Reset)
+	}
+}
+
+func TestAllowPerClient(t *testing.T) {
+	l := New(Config{PerMinute: 1, Burst: 1})
+	if !l.Allow("a").Allowed {
+		t.Fatalf("a 的第一个请求应通过")
+	}
+	if l.Allow("a").Allowed {
+		t.Fatalf("a 的第二个请求应被拒绝")
+	}
+	if !l.Allow("b").Allowed {
+		t.Fatalf("不同客户端的令牌桶应相互独立")
+	}
+}
+
+func TestAllowRefill(t *testing.T) {
+	l := New(Config{PerMinute: 60, Burst: 1})
+	l.Allow("a")
+	// 模拟经过 1.5 秒，补充 1.5 个令牌，但不超过容量
+	l.buckets["a"].updated = l.buckets["a"].updated.Add(-1500 * time.Millisecond)
+	result := l.Allow("a")
+	if !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("result = %+v", result)
+	}
+}
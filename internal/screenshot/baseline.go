@@ -0,0 +1,618 @@
+package screenshot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gotoailab/snapup/internal/models"
+)
+
+// baselineFile 基线记录文件名，位于输出目录中。以点开头，不通过 /screenshots/ 对外提供
+const baselineFile = ".baselines.json"
+
+// maxBaselineHistory 每个基线保留的历史记录数，超出时丢弃最早的记录
+const maxBaselineHistory = 100
+
+// maxBaselineName 基线集名称的最大长度
+const maxBaselineName = 100
+
+// baselineLockTimeout 等待记录文件锁的最长时间，staleBaselineLock 锁文件超过该时间未释放时视为持有锁的进程已退出
+const (
+	baselineLockTimeout = 5 * time.Second
+	staleBaselineLock   = 30 * time.Second
+)
+
+// baselineStore 基线记录。多个进程（如 HTTP 服务和 MCP 服务）可以共用同一个输出目录：
+// 每次修改都在锁文件的保护下重新读取记录文件、修改并完整写回，读取时记录文件有变化则重新加载。
+// 基线属于保存它的客户端，所有操作都只能访问该客户端的基线。
+type baselineStore struct {
+	path string
+
+	mu        sync.Mutex
+	baselines map[string]*models.Baseline
+	modTime   time.Time // 最近一次读取或写入时记录文件的修改时间和大小
+	size      int64
+}
+
+// loadBaselineStore 读取基线记录文件，文件不存在时返回空的记录
+func loadBaselineStore(path string) (*baselineStore, error) {
+	st := &baselineStore{path: path, baselines: make(map[string]*models.Baseline)}
+	if err := st.reload(true); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// reload 重新读取记录文件，force 为 false 时仅在文件的修改时间或大小变化后读取。调用方需持有锁
+func (st *baselineStore) reload(force bool) error {
+	info, err := os.Stat(st.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		st.baselines = make(map[string]*models.Baseline)
+		st.modTime, st.size = time.Time{}, 0
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !force && info.ModTime().Equal(st.modTime) && info.Size() == st.size {
+		return nil
+	}
+
+	data, err := os.ReadFile(st.path)
+	if err != nil {
+		return err
+	}
+	var list []*models.Baseline
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("解析 %s 失败: %w", st.path, err)
+	}
+	baselines := make(map[string]*models.Baseline, len(list))
+	for _, b := range list {
+		baselines[b.ID] = b
+	}
+	st.baselines = baselines
+	st.modTime, st.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// refresh 在读取前加载其他进程写入的修改，读取失败时继续使用内存中的记录。调用方需持有锁
+func (st *baselineStore) refresh() {
+	if err := st.reload(false); err != nil {
+		log.Printf("重新读取基线记录失败: %v", err)
+	}
+}
+
+// lockFile 创建锁文件，在多个进程间互斥修改记录文件，返回释放锁的函数
+func (st *baselineStore) lockFile() (func(), error) {
+	lock := st.path + ".lock"
+	deadline := time.Now().Add(baselineLockTimeout)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > staleBaselineLock {
+			log.Printf("删除过期的基线记录锁文件: %s", lock)
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("等待基线记录锁超时: %s", lock)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// lookup 返回属于 owner 的基线，需持有锁
+func (st *baselineStore) lookup(id, owner string) (*models.Baseline, bool) {
+	b, ok := st.baselines[id]
+	if !ok || b.Owner != owner {
+		return nil, false
+	}
+	return b, true
+}
+
+// get 返回属于 owner 的基线的副本
+func (st *baselineStore) get(id, owner string) (models.Baseline, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.refresh()
+	b, ok := st.lookup(id, owner)
+	if !ok {
+		return models.Baseline{}, false
+	}
+	return cloneBaseline(b), true
+}
+
+// list 返回 owner 在基线集中的所有基线，不包含历史记录。name 为空时返回全部基线，按名称和 URL 排序
+func (st *baselineStore) list(name, owner string) []models.Baseline {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.refresh()
+	list := make([]models.Baseline, 0, len(st.baselines))
+	for _, b := range st.baselines {
+		if b.Owner == owner && (name == "" || b.Name == name) {
+			list = append(list, withoutHistory(cloneBaseline(b)))
+		}
+	}
+	sortBaselines(list)
+	return list
+}
+
+// put 修改属于 owner 的基线（不存在时 exists 为 false，fn 填充新基线）并写入记录文件。
+// fn 返回错误或写入失败时不做任何修改。返回修改后基线的副本。
+func (st *baselineStore) put(id, owner string, fn func(b *models.Baseline, exists bool) error) (models.Baseline, error) {
+	var result models.Baseline
+	err := st.modify(func() error {
+		old, exists := st.lookup(id, owner)
+		var b models.Baseline
+		if exists {
+			b = cloneBaseline(old)
+		}
+		if err := fn(&b, exists); err != nil {
+			return err
+		}
+		if _, taken := st.baselines[id]; taken && !exists {
+			return fmt.Errorf("基线 ID %s 已被其他客户端使用", id)
+		}
+		b.Owner = owner
+		st.baselines[id] = &b
+		result = cloneBaseline(&b)
+		return nil
+	})
+	if err != nil {
+		return models.Baseline{}, err
+	}
+	return result, nil
+}
+
+// delete 删除属于 owner 的基线记录，基线图片和历史截图保留在输出目录中
+func (st *baselineStore) delete(id, owner string) (bool, error) {
+	found := false
+	err := st.modify(func() error {
+		if _, found = st.lookup(id, owner); found {
+			delete(st.baselines, id)
+		}
+		return nil
+	})
+	return found, err
+}
+
+// modify 在锁文件的保护下重新读取记录文件，执行 fn 后写回。fn 返回错误或写入失败时恢复修改前的记录
+func (st *baselineStore) modify(fn func() error) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	unlock, err := st.lockFile()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := st.reload(true); err != nil {
+		return err
+	}
+
+	saved := make(map[string]*models.Baseline, len(st.baselines))
+	for id, b := range st.baselines {
+		saved[id] = b
+	}
+	if err := fn(); err != nil {
+		st.baselines = saved
+		return err
+	}
+	if err := st.persist(); err != nil {
+		st.baselines = saved
+		return err
+	}
+	return nil
+}
+
+// persist 将所有基线写入记录文件。先写入临时文件再重命名，避免写入中断时损坏记录。调用方需持有锁和锁文件
+func (st *baselineStore) persist() error {
+	list := make([]models.Baseline, 0, len(st.baselines))
+	for _, b := range st.baselines {
+		list = append(list, *b)
+	}
+	sortBaselines(list)
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := st.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, st.path); err != nil {
+		return err
+	}
+	if info, err := os.Stat(st.path); err == nil {
+		st.modTime, st.size = info.ModTime(), info.Size()
+	}
+	return nil
+}
+
+// cloneBaseline 复制基线，历史记录不与原基线共用
+func cloneBaseline(b *models.Baseline) models.Baseline {
+	c := *b
+	c.History = append([]models.BaselineRun(nil), b.History...)
+	if b.LastRun != nil {
+		run := *b.LastRun
+		c.LastRun = &run
+	}
+	return c
+}
+
+// withoutHistory 返回不包含历史记录的基线
+func withoutHistory(b models.Baseline) models.Baseline {
+	b.History = nil
+	return b
+}
+
+func sortBaselines(list []models.Baseline) {
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.URL != b.URL {
+			return a.URL < b.URL
+		}
+		return a.ID < b.ID
+	})
+}
+
+// appendRun 追加历史记录，对比记录同时作为最近一次对比的结果
+func appendRun(b *models.Baseline, run models.BaselineRun) {
+	b.History = append(b.History, run)
+	if n := len(b.History) - maxBaselineHistory; n > 0 {
+		b.History = append([]models.BaselineRun(nil), b.History[n:]...)
+	}
+	if run.Status != models.RunApproved {
+		b.LastRun = &run
+	}
+}
+
+// baselineID 返回基线的 ID：所属客户端、基线集名称和补全默认值后的截图参数的摘要。
+// 超时时间和图片质量不影响截图内容，不参与计算。
+func baselineID(owner, name string, req models.ScreenshotRequest) string {
+	req.Timeout = 0
+	req.Quality = 0
+	data, _ := json.Marshal(req)
+	prefix := name + "\n"
+	if owner != "" {
+		prefix = owner + "\n" + prefix
+	}
+	sum := sha256.Sum256(append([]byte(prefix), data...))
+	return hex.EncodeToString(sum[:12])
+}
+
+// normalizeBaseline 验证保存基线请求并填充默认值，返回补全默认值后的截图参数
+func (s *Service) normalizeBaseline(req *models.BaselineRequest) (models.ScreenshotRequest, error) {
+	if req.Name == "" {
+		req.Name = models.DefaultBaselineName
+	}
+	if len(req.Name) > maxBaselineName {
+		return models.ScreenshotRequest{}, invalidRequest("基线集名称长度不能超过 %d", maxBaselineName)
+	}
+	if err := normalizeDiffOptions(&req.Threshold, req.MaxMismatch); err != nil {
+		return models.ScreenshotRequest{}, err
+	}
+
+	shot := req.ScreenshotRequest
+	shot.Format = models.FormatPNG
+	if err := s.validateRequest(&shot); err != nil {
+		return models.ScreenshotRequest{}, err
+	}
+	if _, err := s.devices.Get(shot.Device); err != nil {
+		return models.ScreenshotRequest{}, invalidRequest("%v", err)
+	}
+	return shot, nil
+}
+
+// SaveBaseline 按截图参数截取页面并保存为基线。基线集名称和截图参数相同的基线已存在时替换其基线图片，
+// 保留历史记录；对比参数以本次请求为准。
+func (s *Service) SaveBaseline(ctx context.Context, req models.BaselineRequest) *models.BaselineResponse {
+	shot, err := s.normalizeBaseline(&req)
+	if err != nil {
+		return &models.BaselineResponse{Success: false, Message: err.Error(), Code: ErrorCode(err)}
+	}
+
+	resp, _ := s.TakeScreenshot(ctx, shot)
+	if !resp.Success {
+		return &models.BaselineResponse{Success: false, Message: "截图失败: " + resp.Message, Code: resp.Code}
+	}
+
+	owner := ClientFromContext(ctx)
+	id := baselineID(owner, req.Name, shot)
+	now := time.Now()
+	b, err := s.baselines.put(id, owner, func(b *models.Baseline, exists bool) error {
+		if !exists {
+			b.ID = id
+			b.Name = req.Name
+			b.CreatedAt = now
+		}
+		b.URL = shot.URL
+		b.Device = shot.Device
+		b.Request = shot
+		b.Threshold = *req.Threshold
+		b.IncludeAA = req.IncludeAA
+		b.MaxMismatch = req.MaxMismatch
+		b.Filename = resp.Filename
+		b.ImageURL = resp.ImageURL
+		b.UpdatedAt = now
+		appendRun(b, models.BaselineRun{
+			ID:       uuid.New().String(),
+			Status:   models.RunApproved,
+			Time:     now,
+			Baseline: resp.Filename,
+			Message:  "保存基线",
+		})
+		return nil
+	})
+	if err != nil {
+		err = newPhaseError(ctx, PhaseSave, fmt.Errorf("写入基线记录失败: %w", err))
+		return &models.BaselineResponse{Success: false, Message: err.Error(), Code: ErrorCode(err)}
+	}
+	return &models.BaselineResponse{Success: true, Message: "基线已保存", Baseline: &b}
+}
+
+// Baselines 返回当前客户端在基线集中的所有基线，不包含历史记录。name 为空时返回全部基线
+func (s *Service) Baselines(ctx context.Context, name string) []models.Baseline {
+	return s.baselines.list(name, ClientFromContext(ctx))
+}
+
+// GetBaseline 返回基线及其历史记录，其他客户端的基线视为不存在
+func (s *Service) GetBaseline(ctx context.Context, id string) *models.BaselineResponse {
+	b, ok := s.baselines.get(id, ClientFromContext(ctx))
+	if !ok {
+		err := notFound("基线不存在: %s", id)
+		return &models.BaselineResponse{Success: false, Message: err.Error(), Code: ErrorCode(err)}
+	}
+	return &models.BaselineResponse{Success: true, Baseline: &b}
+}
+
+// DeleteBaseline 删除基线记录，基线图片和历史截图保留在输出目录中
+func (s *Service) DeleteBaseline(ctx context.Context, id string) *models.BaselineResponse {
+	ok, err := s.baselines.delete(id, ClientFromContext(ctx))
+	if err != nil {
+		err = newPhaseError(ctx, PhaseSave, fmt.Errorf("写入基线记录失败: %w", err))
+	} else if !ok {
+		err = notFound("基线不存在: %s", id)
+	}
+	if err != nil {
+		return &models.BaselineResponse{Success: false, Message: err.Error(), Code: ErrorCode(err)}
+	}
+	return &models.BaselineResponse{Success: true, Message: "基线已删除"}
+}
+
+// CompareBaseline 按基线的截图参数重新截图并与基线图片对比，结果记入历史记录
+func (s *Service) CompareBaseline(ctx context.Context, id string) *models.BaselineCompareResponse {
+	owner := ClientFromContext(ctx)
+	b, ok := s.baselines.get(id, owner)
+	if !ok {
+		err := notFound("基线不存在: %s", id)
+		return &models.BaselineCompareResponse{Success: false, Message: err.Error(), Code: ErrorCode(err)}
+	}
+
+	run := s.runBaseline(ctx, b)
+	b, err := s.baselines.put(id, owner, func(b *models.Baseline, exists bool) error {
+		if !exists {
+			return notFound("基线在对比期间被删除: %s", id)
+		}
+		appendRun(b, run)
+		return nil
+	})
+	if err != nil {
+		if ErrorCode(err) != models.CodeNotFound {
+			err = newPhaseError(ctx, PhaseSave, fmt.Errorf("写入基线记录失败: %w", err))
+		}
+		return &models.BaselineCompareResponse{Success: false, Message: err.Error(), Code: ErrorCode(err), Run: &run}
+	}
+
+	b = withoutHistory(b)
+	return &models.BaselineCompareResponse{
+		Success:  run.Status != models.RunError,
+		Message:  run.Message,
+		Code:     run.Code,
+		Passed:   run.Status == models.RunPassed,
+		Baseline: &b,
+		Run:      &run,
+	}
+}
+
+// runBaseline 按基线的截图参数重新截图并与基线图片对比，返回对比记录
+func (s *Service) runBaseline(ctx context.Context, b models.Baseline) models.BaselineRun {
+	run := models.BaselineRun{ID: uuid.New().String(), Time: time.Now(), Baseline: b.Filename}
+	fail := func(prefix string, err error) models.BaselineRun {
+		run.Status = models.RunError
+		run.Code = ErrorCode(err)
+		run.Message = prefix + err.Error()
+		return run
+	}
+
+	resp, _ := s.TakeScreenshot(ctx, b.Request)
+	if !resp.Success {
+		run.Status = models.RunError
+		run.Code = resp.Code
+		run.Message = "截图失败: " + resp.Message
+		return run
+	}
+	run.Candidate, run.CandidateURL = resp.Filename, resp.ImageURL
+
+	diff, err := s.compareFiles(ctx,
+		&models.DiffImage{ImageURL: b.ImageURL, Filename: b.Filename},
+		&models.DiffImage{URL: b.URL, ImageURL: resp.ImageURL, Filename: resp.Filename},
+		DiffOptions{Threshold: b.Threshold, IncludeAA: b.IncludeAA})
+	if err != nil {
+		return fail("对比失败: ", err)
+	}
+	run.Diff, run.DiffURL = diff.Filename, diff.ImageURL
+	run.Mismatch, run.DiffPixels, run.SizeMismatch = diff.Mismatch, diff.DiffPixels, diff.SizeMismatch
+
+	if diff.Mismatch <= b.MaxMismatch {
+		run.Status = models.RunPassed
+		run.Message = "对比通过"
+	} else {
+		run.Status = models.RunFailed
+		run.Message = fmt.Sprintf("不同像素占 %.4f%%，超过允许的 %g%%", diff.Mismatch, b.MaxMismatch)
+	}
+	return run
+}
+
+// ApproveBaseline 将一次对比截取的图片批准为新的基线图片。runID 为空时批准最近一次截图成功的对比
+func (s *Service) ApproveBaseline(ctx context.Context, id, runID string) *models.BaselineResponse {
+	b, err := s.baselines.put(id, ClientFromContext(ctx), func(b *models.Baseline, exists bool) error {
+		if !exists {
+			return notFound("基线不存在: %s", id)
+		}
+
+		var run *models.BaselineRun
+		for i := len(b.History) - 1; i >= 0; i-- {
+			h := &b.History[i]
+			if (runID == "" && h.Candidate != "") || (runID != "" && h.ID == runID) {
+				run = h
+				break
+			}
+		}
+		switch {
+		case run == nil && runID == "":
+			return invalidRequest("没有可批准的对比记录")
+		case run == nil:
+			return notFound("对比记录不存在: %s", runID)
+		case run.Candidate == "":
+			return invalidRequest("对比记录 %s 没有截取到图片", runID)
+		case run.Candidate == b.Filename:
+			return invalidRequest("对比记录 %s 的图片已是当前基线", run.ID)
+		}
+		if info, err := os.Lstat(filepath.Join(s.outputDir, run.Candidate)); err != nil || !info.Mode().IsRegular() {
+			return notFound("截图文件不存在: %s", run.Candidate)
+		}
+
+		now := time.Now()
+		b.Filename = run.Candidate
+		b.ImageURL = run.CandidateURL
+		b.UpdatedAt = now
+		appendRun(b, models.BaselineRun{
+			ID:       uuid.New().String(),
+			Status:   models.RunApproved,
+			Time:     now,
+			Baseline: run.Candidate,
+			Message:  "批准对比记录 " + run.ID + " 的截图为基线",
+		})
+		return nil
+	})
+	if err != nil {
+		var re *requestError
+		if !errors.As(err, &re) {
+			err = newPhaseError(ctx, PhaseSave, fmt.Errorf("写入基线记录失败: %w", err))
+		}
+		return &models.BaselineResponse{Success: false, Message: err.Error(), Code: ErrorCode(err)}
+	}
+	return &models.BaselineResponse{Success: true, Message: "基线已更新", Baseline: &b}
+}
+
+// CheckBaselines 对基线集中的所有基线重新截图并对比，结果记入各基线的历史记录。
+// 页面并发截图，并发数不超过每个客户端的上限。progress 不为空时在每个页面完成后调用。
+func (s *Service) CheckBaselines(ctx context.Context, req models.BaselineCheckRequest, progress func(done, total int)) *models.BaselineCheckResponse {
+	owner := ClientFromContext(ctx)
+	list := s.baselines.list(req.Name, owner)
+	resp := &models.BaselineCheckResponse{
+		Status: models.CheckPassed,
+		Total:  len(list),
+		Pages:  make([]models.BaselineCheckItem, len(list)),
+	}
+	if len(list) == 0 {
+		resp.Status = models.CheckFailed
+		if req.Name == "" {
+			resp.Message = "没有基线"
+		} else {
+			resp.Message = fmt.Sprintf("基线集 %s 中没有基线", req.Name)
+		}
+		return resp
+	}
+
+	// 检查已被接受，各页面在客户端达到并发上限时排队等待，而不是因为同一客户端的其他请求被拒绝
+	ctx = WithQueueWait(ctx)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		done int
+		sem  = make(chan struct{}, s.batchConcurrency())
+	)
+	if progress != nil {
+		progress(0, len(list))
+	}
+	for i, b := range list {
+		wg.Add(1)
+		go func(i int, b models.Baseline) {
+			defer wg.Done()
+
+			var run models.BaselineRun
+			select {
+			case sem <- struct{}{}:
+				run = s.runBaseline(ctx, b)
+				<-sem
+			case <-ctx.Done():
+				run = models.BaselineRun{
+					ID:       uuid.New().String(),
+					Status:   models.RunError,
+					Time:     time.Now(),
+					Baseline: b.Filename,
+					Code:     ErrorCode(ctx.Err()),
+					Message:  "检查已取消",
+				}
+			}
+			if _, err := s.baselines.put(b.ID, owner, func(cur *models.Baseline, exists bool) error {
+				if !exists {
+					return notFound("基线在对比期间被删除: %s", b.ID)
+				}
+				appendRun(cur, run)
+				return nil
+			}); err != nil {
+				log.Printf("记录基线 %s 的对比结果失败: %v", b.ID, err)
+			}
+			resp.Pages[i] = models.BaselineCheckItem{BaselineID: b.ID, Name: b.Name, URL: b.URL, Device: b.Device, BaselineRun: &run}
+
+			mu.Lock()
+			done++
+			if progress != nil {
+				progress(done, len(list))
+			}
+			mu.Unlock()
+		}(i, b)
+	}
+	wg.Wait()
+
+	for _, page := range resp.Pages {
+		switch page.Status {
+		case models.RunPassed:
+			resp.Passed++
+		case models.RunFailed:
+			resp.Failed++
+		default:
+			resp.Errors++
+		}
+	}
+	if resp.Passed < resp.Total {
+		resp.Status = models.CheckFailed
+	}
+	return resp
+}
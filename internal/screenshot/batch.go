@@ -0,0 +1,148 @@
+package screenshot
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gotoailab/snapup/internal/models"
+)
+
+// expandBatch 展开批量请求，按 URL、设备、样式的顺序返回每个组合的截图请求
+func expandBatch(req models.BatchRequest) []models.ScreenshotRequest {
+	devices := append([]models.DeviceType(nil), req.Devices...)
+	if len(devices) == 0 {
+		devices = []models.DeviceType{req.Device}
+	}
+	styles := append([]models.MockupStyle(nil), req.Styles...)
+	if len(styles) == 0 {
+		styles = []models.MockupStyle{req.Style}
+	}
+	for i, device := range devices {
+		if device == "" {
+			devices[i] = models.DeviceDesktop
+		}
+	}
+	for i, style := range styles {
+		if style == "" {
+			styles[i] = models.StyleNone
+		}
+	}
+
+	items := make([]models.ScreenshotRequest, 0, len(req.URLs)*len(devices)*len(styles))
+	for _, u := range req.URLs {
+		for _, device := range devices {
+			for _, style := range styles {
+				item := req.ScreenshotRequest
+				item.URL = u
+				item.Device = device
+				item.Style = style
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// ValidateBatch 检查批量请求。组合数量超出上限或共用参数（设备、样式等）无效时返回错误；
+// 单个 URL 无效不影响整批，在结果中作为该项的失败返回。
+func (s *Service) ValidateBatch(req models.BatchRequest) error {
+	if len(req.URLs) == 0 {
+		return invalidRequest("urls 不能为空")
+	}
+	items := expandBatch(req)
+	if len(items) > s.maxBatchSize {
+		return invalidRequest("批量截图最多 %d 个组合，当前 %d 个（%d 个 URL × %d 个设备 × %d 个样式）",
+			s.maxBatchSize, len(items), len(req.URLs), max(len(req.Devices), 1), max(len(req.Styles), 1))
+	}
+
+	for _, item := range items {
+		err := s.validateRequest(&item)
+		if err == nil {
+			_, err = s.devices.Get(item.Device)
+			if err != nil {
+				err = invalidRequest("%v", err)
+			}
+		}
+		if err != nil && ErrorCode(err) != models.CodeInvalidURL {
+			return err
+		}
+	}
+	return nil
+}
+
+// TakeBatch 执行批量截图。各组合并发执行，并发数不超过每个客户端的上限，
+// 单个组合失败不影响其他组合。progress 不为空时在每个组合完成后调用。
+// 批量请求已被接受，各组合在客户端达到并发上限时排队等待，而不是因为同一客户端的其他请求被拒绝。
+func (s *Service) TakeBatch(ctx context.Context, req models.BatchRequest, progress func(done, total int)) *models.BatchResponse {
+	ctx = WithQueueWait(ctx)
+	items := expandBatch(req)
+	resp := &models.BatchResponse{
+		Total: len(items),
+		Items: make([]models.BatchItem, len(items)),
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		done int
+		sem  = make(chan struct{}, s.batchConcurrency())
+	)
+	if progress != nil {
+		progress(0, len(items))
+	}
+	for i, item := range items {
+		wg.Add(1)
+		go func(i int, item models.ScreenshotRequest) {
+			defer wg.Done()
+
+			var result *models.ScreenshotResponse
+			select {
+			case sem <- struct{}{}:
+				result, _ = s.TakeScreenshot(ctx, item)
+				<-sem
+			case <-ctx.Done():
+				result = &models.ScreenshotResponse{
+					Success: false,
+					Message: "批量截图已取消",
+					Code:    ErrorCode(ctx.Err()),
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			resp.Items[i] = models.BatchItem{URL: item.URL, Device: item.Device, Style: item.Style, ScreenshotResponse: result}
+			if result.Success {
+				resp.Succeeded++
+			} else {
+				resp.Failed++
+			}
+			done++
+			if progress != nil {
+				progress(done, len(items))
+			}
+		}(i, item)
+	}
+	wg.Wait()
+
+	switch {
+	case resp.Failed == 0:
+		resp.Status = models.BatchSuccess
+	case resp.Succeeded == 0:
+		resp.Status = models.BatchFailed
+	default:
+		resp.Status = models.BatchPartial
+	}
+	return resp
+}
+
+// batchConcurrency 批量截图的并发数：不超过每个客户端的并发上限，避免后续组合因超限被拒绝
+func (s *Service) batchConcurrency() int {
+	switch {
+	case s.queue.config.MaxPerClient > 0:
+		return s.queue.config.MaxPerClient
+	case s.queue.config.Workers > 0:
+		return s.queue.config.Workers
+	default:
+		return 4
+	}
+}
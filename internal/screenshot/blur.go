@@ -0,0 +1,271 @@
+package screenshot
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// samplePlane 对单通道平面做双线性采样，越界坐标按边缘像素处理
+func samplePlane(plane []float32, w, h int, fx, fy float32) float32 {
+	if fx < 0 {
+		fx = 0
+	}
+	if fy < 0 {
+		fy = 0
+	}
+	x0, y0 := int(fx), int(fy)
+	if x0 >= w-1 {
+		x0, fx = w-1, float32(w-1)
+	}
+	if y0 >= h-1 {
+		y0, fy = h-1, float32(h-1)
+	}
+	x1, y1 := min(x0+1, w-1), min(y0+1, h-1)
+	tx, ty := fx-float32(x0), fy-float32(y0)
+
+	top := plane[y0*w+x0]*(1-tx) + plane[y0*w+x1]*tx
+	bottom := plane[y1*w+x0]*(1-tx) + plane[y1*w+x1]*tx
+	return top*(1-ty) + bottom*ty
+}
+
+// gaussianKernel 生成归一化的一维高斯核，核半径取 3σ
+func gaussianKernel(sigma float64) []float32 {
+	if sigma <= 0 {
+		return []float32{1}
+	}
+
+	radius := int(math.Ceil(sigma * 3))
+	kernel := make([]float32, radius*2+1)
+	var sum float64
+	for i := -radius; i <= radius; i++ {
+		v := math.Exp(-float64(i*i) / (2 * sigma * sigma))
+		kernel[i+radius] = float32(v)
+		sum += v
+	}
+	for i := range kernel {
+		kernel[i] = float32(float64(kernel[i]) / sum)
+	}
+	return kernel
+}
+
+// blurPlane 对单通道平面做可分离的高斯模糊（先水平后垂直），结果写回 plane
+func blurPlane(plane []float32, w, h int, kernel []float32) {
+	if len(kernel) <= 1 || w == 0 || h == 0 {
+		return
+	}
+
+	n := max(w, h)
+	line := make([]float32, n)
+	out := make([]float32, n)
+	runEnd := make([]int, n)
+
+	// 水平方向
+	for y := 0; y < h; y++ {
+		row := plane[y*w : (y+1)*w]
+		copy(line[:w], row)
+		convolve(line[:w], row, kernel, runEnd)
+	}
+
+	// 垂直方向
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			line[y] = plane[y*w+x]
+		}
+		convolve(line[:h], out[:h], kernel, runEnd)
+		for y := 0; y < h; y++ {
+			plane[y*w+x] = out[y]
+		}
+	}
+}
+
+// convolve 对一行数据做一维卷积，边界按最近像素延伸
+//
+// 窗口内数值完全相同时直接复制（遮罩和大面积纯色区域的常见情况），
+// 只在变化区域附近真正计算卷积。
+func convolve(src, dst []float32, kernel []float32, runEnd []int) {
+	n := len(src)
+	radius := len(kernel) / 2
+
+	// runEnd[i] 表示从 i 开始数值保持不变的最后一个下标
+	runEnd[n-1] = n - 1
+	for i := n - 2; i >= 0; i-- {
+		if src[i] == src[i+1] {
+			runEnd[i] = runEnd[i+1]
+		} else {
+			runEnd[i] = i
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		lo, hi := i-radius, i+radius
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > n-1 {
+			hi = n - 1
+		}
+		if runEnd[lo] >= hi {
+			dst[i] = src[lo]
+			continue
+		}
+
+		var sum float32
+		for k := -radius; k <= radius; k++ {
+			j := i + k
+			if j < 0 {
+				j = 0
+			} else if j >= n {
+				j = n - 1
+			}
+			sum += src[j] * kernel[k+radius]
+		}
+		dst[i] = sum
+	}
+}
+
+// blurImage 对图片做高斯模糊，四个通道（预乘 alpha）分别进行可分离卷积
+func blurImage(img image.Image, sigma float64) *image.RGBA {
+	src := toRGBA(img)
+	if sigma <= 0 {
+		return src
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	planes := [4][]float32{}
+	for c := range planes {
+		planes[c] = make([]float32, w*h)
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*src.Stride + x*4
+			for c := 0; c < 4; c++ {
+				planes[c][y*w+x] = float32(src.Pix[i+c])
+			}
+		}
+	}
+
+	kernel := gaussianKernel(sigma)
+	for c := range planes {
+		blurPlane(planes[c], w, h, kernel)
+	}
+
+	result := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*result.Stride + x*4
+			for c := 0; c < 4; c++ {
+				result.Pix[i+c] = clampUint8(planes[c][y*w+x])
+			}
+		}
+	}
+	return result
+}
+
+// resizeImage 缩放图片：缩小时按区域取平均，放大时双线性插值
+func resizeImage(img image.Image, width, height int) *image.RGBA {
+	src := toRGBA(img)
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	result := image.NewRGBA(image.Rect(0, 0, width, height))
+	if sw == 0 || sh == 0 || width <= 0 || height <= 0 {
+		return result
+	}
+
+	scaleX := float64(sw) / float64(width)
+	scaleY := float64(sh) / float64(height)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var px [4]float64
+			if scaleX > 1 || scaleY > 1 {
+				px = areaAverage(src, float64(x)*scaleX, float64(y)*scaleY, scaleX, scaleY)
+			} else {
+				px = bilinear(src, (float64(x)+0.5)*scaleX-0.5, (float64(y)+0.5)*scaleY-0.5)
+			}
+			i := y*result.Stride + x*4
+			for c := 0; c < 4; c++ {
+				result.Pix[i+c] = clampUint8(float32(px[c]))
+			}
+		}
+	}
+	return result
+}
+
+// coverImage 按 "cover" 方式缩放并居中裁剪图片，使其铺满 width x height
+func coverImage(img image.Image, width, height int) *image.RGBA {
+	b := img.Bounds()
+	scale := math.Max(float64(width)/float64(b.Dx()), float64(height)/float64(b.Dy()))
+	sw := int(math.Ceil(float64(b.Dx()) * scale))
+	sh := int(math.Ceil(float64(b.Dy()) * scale))
+	scaled := resizeImage(img, sw, sh)
+
+	result := image.NewRGBA(image.Rect(0, 0, width, height))
+	offset := image.Pt((sw-width)/2, (sh-height)/2)
+	draw.Draw(result, result.Bounds(), scaled, offset, draw.Src)
+	return result
+}
+
+// areaAverage 计算源图中 [x, x+w) x [y, y+h) 区域的平均像素值
+func areaAverage(src *image.RGBA, x, y, w, h float64) [4]float64 {
+	x0, y0 := int(x), int(y)
+	x1 := min(int(math.Ceil(x+w)), src.Bounds().Dx())
+	y1 := min(int(math.Ceil(y+h)), src.Bounds().Dy())
+	x1, y1 = max(x1, x0+1), max(y1, y0+1)
+
+	var sum [4]float64
+	for yy := y0; yy < y1; yy++ {
+		for xx := x0; xx < x1; xx++ {
+			i := yy*src.Stride + xx*4
+			for c := 0; c < 4; c++ {
+				sum[c] += float64(src.Pix[i+c])
+			}
+		}
+	}
+	n := float64((x1 - x0) * (y1 - y0))
+	for c := range sum {
+		sum[c] /= n
+	}
+	return sum
+}
+
+// bilinear 对源图做双线性采样
+func bilinear(src *image.RGBA, fx, fy float64) [4]float64 {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	fx = math.Max(0, math.Min(fx, float64(w-1)))
+	fy = math.Max(0, math.Min(fy, float64(h-1)))
+	x0, y0 := int(fx), int(fy)
+	x1, y1 := min(x0+1, w-1), min(y0+1, h-1)
+	tx, ty := fx-float64(x0), fy-float64(y0)
+
+	var px [4]float64
+	for c := 0; c < 4; c++ {
+		p00 := float64(src.Pix[y0*src.Stride+x0*4+c])
+		p10 := float64(src.Pix[y0*src.Stride+x1*4+c])
+		p01 := float64(src.Pix[y1*src.Stride+x0*4+c])
+		p11 := float64(src.Pix[y1*src.Stride+x1*4+c])
+		px[c] = (p00*(1-tx)+p10*tx)*(1-ty) + (p01*(1-tx)+p11*tx)*ty
+	}
+	return px
+}
+
+// toRGBA 将任意图片转换为以 (0, 0) 为原点的 RGBA 图片
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	return rgba
+}
+
+// clampUint8 将浮点数截断到 0-255 并四舍五入
+func clampUint8(v float32) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 255 {
+		return 255
+	}
+	return uint8(v + 0.5)
+}
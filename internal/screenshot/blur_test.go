@@ -0,0 +1,137 @@
+package screenshot
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestGaussianKernel(t *testing.T) {
+	tests := []struct {
+		sigma float64
+		size  int
+	}{
+		{0, 1},
+		{-1, 1},
+		{0.2, 3},
+		{1, 7},
+		{2.5, 17},
+		{10, 61},
+	}
+	for _, tt := range tests {
+		kernel := gaussianKernel(tt.sigma)
+		if len(kernel) != tt.size {
+			t.Fatalf("sigma %v: len = %d, want %d", tt.sigma, len(kernel), tt.size)
+		}
+		var sum float64
+		for i, v := range kernel {
+			sum += float64(v)
+			if mirror := kernel[len(kernel)-1-i]; v != mirror {
+				t.Fatalf("sigma %v: 核不对称: kernel[%d] = %v, mirror = %v", tt.sigma, i, v, mirror)
+			}
+			if i > 0 && i <= len(kernel)/2 && v < kernel[i-1] {
+				t.Fatalf("sigma %v: 核应向中心递增", tt.sigma)
+			}
+		}
+		if math.Abs(sum-1) > 1e-5 {
+			t.Fatalf("sigma %v: sum = %v, want 1", tt.sigma, sum)
+		}
+	}
+}
+
+func TestConvolve(t *testing.T) {
+	kernel := []float32{0.25, 0.5, 0.25}
+	tests := []struct {
+		name string
+		src  []float32
+		want []float32
+	}{
+		{"纯色保持不变", []float32{7, 7, 7, 7}, []float32{7, 7, 7, 7}},
+		{"单点扩散", []float32{0, 0, 4, 0, 0}, []float32{0, 1, 2, 1, 0}},
+		{"边界按最近像素延伸", []float32{4, 0, 0}, []float32{3, 1, 0}},
+		{"阶跃", []float32{0, 0, 8, 8}, []float32{0, 2, 6, 8}},
+		{"单个像素", []float32{5}, []float32{5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := make([]float32, len(tt.src))
+			convolve(tt.src, dst, kernel, make([]int, len(tt.src)))
+			for i := range dst {
+				if math.Abs(float64(dst[i]-tt.want[i])) > 1e-6 {
+					t.Fatalf("dst = %v, want %v", dst, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// naiveBlur 逐像素计算二维高斯卷积，用于对照可分离卷积的结果
+func naiveBlur(plane []float32, w, h int, kernel []float32) []float32 {
+	r := len(kernel) / 2
+	clamp := func(v, n int) int { return min(max(v, 0), n-1) }
+	out := make([]float32, len(plane))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var sum float32
+			for ky := -r; ky <= r; ky++ {
+				for kx := -r; kx <= r; kx++ {
+					sum += plane[clamp(y+ky, h)*w+clamp(x+kx, w)] * kernel[ky+r] * kernel[kx+r]
+				}
+			}
+			out[y*w+x] = sum
+		}
+	}
+	return out
+}
+
+func TestBlurPlaneMatchesNaive(t *testing.T) {
+	const w, h = 13, 9
+	plane := make([]float32, w*h)
+	for i := range plane {
+		plane[i] = float32((i * 37) % 255)
+	}
+	// 加入大块纯色区域，覆盖跳过卷积的分支
+	for y := 0; y < h; y++ {
+		for x := 0; x < 5; x++ {
+			plane[y*w+x] = 100
+		}
+	}
+	kernel := gaussianKernel(1.3)
+	want := naiveBlur(plane, w, h, kernel)
+	blurPlane(plane, w, h, kernel)
+	for i := range plane {
+		if math.Abs(float64(plane[i]-want[i])) > 1e-2 {
+			t.Fatalf("pixel %d = %v, want %v", i, plane[i], want[i])
+		}
+	}
+}
+
+func TestBlurImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 21, 21))
+	img.Set(10, 10, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+
+	if same := blurImage(img, 0); same.RGBAAt(10, 10).A != 255 || same.RGBAAt(9, 10).A != 0 {
+		t.Fatalf("sigma 为 0 时不应模糊")
+	}
+
+	blurred := blurImage(img, 2)
+	center := blurred.RGBAAt(10, 10).A
+	if center == 0 || center == 255 {
+		t.Fatalf("center alpha = %d", center)
+	}
+	// 四个方向对称扩散，距离越远越淡
+	for d := 1; d <= 4; d++ {
+		left, right := blurred.RGBAAt(10-d, 10).A, blurred.RGBAAt(10+d, 10).A
+		up, down := blurred.RGBAAt(10, 10-d).A, blurred.RGBAAt(10, 10+d).A
+		if left != right || up != down || left != up {
+			t.Fatalf("距离 %d 处不对称: %d %d %d %d", d, left, right, up, down)
+		}
+		if left > blurred.RGBAAt(10-d+1, 10).A {
+			t.Fatalf("距离 %d 处比内侧更亮", d)
+		}
+	}
+	if corner := blurred.RGBAAt(0, 0).A; corner != 0 {
+		t.Fatalf("3σ 之外不应受影响: %d", corner)
+	}
+}
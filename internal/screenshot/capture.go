@@ -1,103 +1,149 @@
 package screenshot
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
-	"os"
+	"image/png"
+	"math"
 	"time"
 
 	"github.com/gotoailab/snapup/internal/models"
+	"github.com/gotoailab/snapup/internal/urlpolicy"
 
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/page"
 	"github.com/chromedp/chromedp"
 )
 
 // Capturer 截图捕获器接口
+//
+// 返回的 CaptureResult 总是非 nil，失败时其中包含已完成阶段的耗时，
+// 错误为 *PhaseError，指明失败的阶段。
 type Capturer interface {
-	Capture(ctx context.Context, req models.ScreenshotRequest) ([]byte, error)
+	Capture(ctx context.Context, req models.ScreenshotRequest, device models.DeviceConfig) (*CaptureResult, error)
+}
+
+// CaptureResult 截图结果
+type CaptureResult struct {
+	Data    []byte
+	Timings models.Timings
+	Page    *models.PageInfo // 导航成功后才有值
 }
 
 // ChromeCapture Chrome 截图捕获器
 type ChromeCapture struct {
-	chromeWSURL string
+	pool   *BrowserPool
+	policy *urlpolicy.Policy // 为 nil 时不限制页面访问的地址
+}
+
+// NewChromeCapture 创建 Chrome 截图捕获器，页面发出的所有请求都按 policy 检查
+func NewChromeCapture(policy *urlpolicy.Policy) *ChromeCapture {
+	return NewChromeCaptureWithPool(NewBrowserPool(PoolConfigFromEnv()), policy)
 }
 
-// NewChromeCapture 创建 Chrome 截图捕获器
-func NewChromeCapture() *ChromeCapture {
+// NewChromeCaptureWithPool 使用指定的浏览器池创建 Chrome 截图捕获器
+func NewChromeCaptureWithPool(pool *BrowserPool, policy *urlpolicy.Policy) *ChromeCapture {
 	return &ChromeCapture{
-		chromeWSURL: os.Getenv("CHROME_WS_URL"),
+		pool:   pool,
+		policy: policy,
 	}
 }
 
-// Capture 执行截图
-func (c *ChromeCapture) Capture(ctx context.Context, req models.ScreenshotRequest) ([]byte, error) {
-	// 获取设备配置
-	deviceConfig := models.GetDeviceConfig(req.Device)
-
-	var taskCtx context.Context
-	var cancel context.CancelFunc
-
-	// 根据是否配置了远程 Chrome 来创建上下文
-	if c.chromeWSURL != "" {
-		// 使用远程 Chrome（Docker 容器）
-		allocCtx, allocCancel := chromedp.NewRemoteAllocator(ctx, c.chromeWSURL)
-		defer allocCancel()
-
-		taskCtx, cancel = chromedp.NewContext(allocCtx)
-		defer cancel()
-	} else {
-		// 使用本地 Chrome
-		opts := append(chromedp.DefaultExecAllocatorOptions[:],
-			chromedp.Flag("headless", true),
-			chromedp.Flag("disable-gpu", true),
-			chromedp.Flag("no-sandbox", true),
-			chromedp.Flag("disable-dev-shm-usage", true),
-			chromedp.Flag("disable-setuid-sandbox", true),
-			chromedp.WindowSize(int(deviceConfig.Width), int(deviceConfig.Height)),
-		)
-
-		allocCtx, allocCancel := chromedp.NewExecAllocator(ctx, opts...)
-		defer allocCancel()
-
-		taskCtx, cancel = chromedp.NewContext(allocCtx)
-		defer cancel()
-	}
-
-	// 设置超时
-	taskCtx, cancel = context.WithTimeout(taskCtx, 30*time.Second)
+// Stats 返回浏览器池统计信息
+func (c *ChromeCapture) Stats() PoolStats {
+	return c.pool.Stats()
+}
+
+// Close 关闭浏览器池
+func (c *ChromeCapture) Close() error {
+	return c.pool.Close()
+}
+
+// Capture 执行截图，超时由 ctx 控制
+func (c *ChromeCapture) Capture(ctx context.Context, req models.ScreenshotRequest, deviceConfig models.DeviceConfig) (*CaptureResult, error) {
+	result := &CaptureResult{}
+	timings := &result.Timings
+
+	// 从浏览器池借出浏览器，并在独立的无痕上下文中打开新标签页
+	start := time.Now()
+	lease, err := c.pool.Acquire(ctx)
+	if err != nil {
+		return result, newPhaseError(ctx, PhaseBrowserStart, err)
+	}
+	defer lease.Release()
+
+	taskCtx, cancel := lease.NewTab(ctx)
 	defer cancel()
 
-	// 截图缓冲区
-	var buf []byte
-
-	// 构建任务列表
-	tasks := chromedp.Tasks{}
-	
-	// 设置视口和设备模拟
-	if deviceConfig.Mobile {
-		// 移动设备模拟
-		tasks = append(tasks,
-			chromedp.EmulateViewport(deviceConfig.Width, deviceConfig.Height, 
-				chromedp.EmulateScale(deviceConfig.Scale)),
-		)
-	} else {
-		// 桌面设备
-		tasks = append(tasks,
-			chromedp.EmulateViewport(deviceConfig.Width, deviceConfig.Height),
-		)
-	}
-	
-	// 导航到目标 URL
-	tasks = append(tasks, chromedp.Navigate(req.URL))
-
-	// 如果需要延迟
+	// 在导航之前开始跟踪 load 事件和网络请求，并拦截所有请求检查访问策略
+	watcher := watchPage(taskCtx)
+	guard, enableGuard := guardRequests(taskCtx, cancel, c.policy)
+
+	// run 执行一个阶段的任务并记录耗时。违规连接会关闭标签页，此时报告违规的原因
+	run := func(phase Phase, elapsed *int64, actions ...chromedp.Action) error {
+		start := time.Now()
+		err := chromedp.Run(taskCtx, actions...)
+		*elapsed = time.Since(start).Milliseconds()
+		if blocked := guard.blocked(); blocked != nil {
+			return newPhaseError(ctx, phase, blocked)
+		}
+		if err != nil {
+			lease.MarkFailed()
+			return newPhaseError(ctx, phase, err)
+		}
+		return nil
+	}
+
+	if err := chromedp.Run(taskCtx, enableGuard); err != nil {
+		lease.MarkFailed()
+		return result, newPhaseError(ctx, PhaseBrowserStart, err)
+	}
+	timings.BrowserStart = time.Since(start).Milliseconds()
+
+	// 设置视口和设备模拟，导航到目标 URL
+	if err := run(PhaseNavigation, &timings.Navigation,
+		emulateDevice(deviceConfig),
+		navigate(watcher, req.URL),
+	); err != nil {
+		var navErr *navigationError
+		if errors.As(err, &navErr) && navErr.blockedByClient() && guard.violation() != nil {
+			return result, newPhaseError(ctx, PhaseNavigation, guard.violation())
+		}
+		return result, err
+	}
+	result.Page = &models.PageInfo{FinalURL: req.URL}
+	if resp := watcher.response; resp != nil {
+		result.Page.Status = resp.Status
+		result.Page.FinalURL = resp.URL
+
+		accept := models.DefaultAcceptStatus
+		if req.AcceptStatus != nil {
+			accept = *req.AcceptStatus
+		}
+		if !accept.Contains(resp.Status) {
+			return result, newPhaseError(ctx, PhaseNavigation, &httpStatusError{status: resp.Status, text: resp.StatusText})
+		}
+	}
+
+	// 等待页面就绪，未指定等待条件时等待 load 事件，之后再等待额外的稳定时间
+	conds := req.Wait
+	if len(conds) == 0 {
+		conds = []models.WaitCondition{{Type: models.WaitLoad, Timeout: models.DefaultWaitTimeout}}
+	}
+	waitTasks := chromedp.Tasks{waitConditions(watcher, conds)}
 	if req.Delay > 0 {
-		tasks = append(tasks, chromedp.Sleep(time.Duration(req.Delay)*time.Millisecond))
-	} else {
-		// 默认等待页面加载完成
-		tasks = append(tasks, chromedp.Sleep(1*time.Second))
+		waitTasks = append(waitTasks, chromedp.Sleep(time.Duration(req.Delay)*time.Millisecond))
+	}
+	err = run(PhaseWait, &timings.Wait, waitTasks)
+	result.Page.LoadTime = watcher.loadTime().Milliseconds()
+	if err != nil {
+		return result, err
 	}
 
-	// 隐藏滚动条
+	// 隐藏滚动条并截图
 	hideScrollbarJS := `
 		(function() {
 			const style = document.createElement('style');
@@ -105,19 +151,213 @@ func (c *ChromeCapture) Capture(ctx context.Context, req models.ScreenshotReques
 			document.head.appendChild(style);
 		})();
 	`
-	tasks = append(tasks, chromedp.Evaluate(hideScrollbarJS, nil))
+	var info struct {
+		Title string   `json:"title"`
+		URL   string   `json:"url"`
+		Links []string `json:"links"`
+	}
+	pageInfoJS := `({title: document.title, url: location.href})`
+	collect := linkCollectorFromContext(ctx)
+	if collect != nil {
+		pageInfoJS = `({title: document.title, url: location.href, links: Array.from(document.links, a => a.href)})`
+	}
+	if err := run(PhaseCapture, &timings.Capture,
+		chromedp.Evaluate(pageInfoJS, &info),
+		chromedp.Evaluate(hideScrollbarJS, nil),
+		captureScreenshot(&result.Data, req),
+	); err != nil {
+		return result, err
+	}
+	// 截图前收到的违规响应可能已经显示在页面上，此时不返回截图
+	if blocked := guard.blocked(); blocked != nil {
+		result.Data = nil
+		return result, newPhaseError(ctx, PhaseCapture, blocked)
+	}
+	result.Page.Title = info.Title
+	result.Page.FinalURL = info.URL
+	if collect != nil {
+		collect(info.Links)
+	}
+
+	return result, nil
+}
+
+// emulateDevice 按设备配置模拟视口、屏幕方向、触摸和 User-Agent
+func emulateDevice(device models.DeviceConfig) chromedp.Tasks {
+	orientation := &emulation.ScreenOrientation{Type: emulation.OrientationTypePortraitPrimary, Angle: 0}
+	if device.Landscape() {
+		orientation = &emulation.ScreenOrientation{Type: emulation.OrientationTypeLandscapePrimary, Angle: 90}
+	}
+
+	touch := emulation.SetTouchEmulationEnabled(device.Touch)
+	if device.Touch {
+		touch = touch.WithMaxTouchPoints(5)
+	}
+
+	tasks := chromedp.Tasks{
+		emulation.SetDeviceMetricsOverride(device.Width, device.Height, device.Scale, device.Mobile).
+			WithScreenOrientation(orientation),
+		touch,
+	}
+
+	if userAgent, platform := device.Emulation(); userAgent != "" {
+		override := emulation.SetUserAgentOverride(userAgent)
+		if platform != "" {
+			override = override.WithPlatform(platform)
+		}
+		tasks = append(tasks, override)
+	}
+	return tasks
+}
+
+// captureScreenshot 按请求的格式和质量截取视口、整个页面、指定元素或指定区域
+func captureScreenshot(res *[]byte, req models.ScreenshotRequest) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		params := page.CaptureScreenshot().
+			WithFromSurface(true).
+			WithCaptureBeyondViewport(req.FullPage)
+
+		switch {
+		case req.Selector != "":
+			clip, err := elementClip(ctx, req.Selector, req.Padding)
+			if err != nil {
+				return err
+			}
+			params = params.WithClip(clip).WithCaptureBeyondViewport(true)
+		case req.Clip != nil:
+			params = params.WithClip(&page.Viewport{
+				X:      req.Clip.X,
+				Y:      req.Clip.Y,
+				Width:  req.Clip.Width,
+				Height: req.Clip.Height,
+				Scale:  1,
+			}).WithCaptureBeyondViewport(true)
+		}
+
+		switch req.Format {
+		case models.FormatJPEG:
+			params = params.WithFormat(page.CaptureScreenshotFormatJpeg).WithQuality(int64(req.Quality))
+		case models.FormatWebP:
+			params = params.WithFormat(page.CaptureScreenshotFormatWebp).WithQuality(int64(req.Quality))
+		default:
+			params = params.WithFormat(page.CaptureScreenshotFormatPng)
+		}
+
+		var err error
+		*res, err = params.Do(ctx)
+		return err
+	})
+}
+
+// elementBoundsJS 将元素滚动到可见区域并返回其在文档中的位置，元素不存在时返回 null
+const elementBoundsJS = `(() => {
+	const el = document.querySelector(%s);
+	if (!el) return null;
+	el.scrollIntoView({block: 'center', inline: 'center'});
+	const rect = el.getBoundingClientRect();
+	const doc = document.documentElement;
+	return {
+		x: rect.left + window.scrollX,
+		y: rect.top + window.scrollY,
+		width: rect.width,
+		height: rect.height,
+		pageWidth: Math.max(doc.scrollWidth, doc.clientWidth),
+		pageHeight: Math.max(doc.scrollHeight, doc.clientHeight),
+	};
+})()`
+
+// elementClip 计算选择器匹配元素加上边距后的截取区域，区域不会超出文档范围
+func elementClip(ctx context.Context, selector string, padding int) (*page.Viewport, error) {
+	var bounds *struct {
+		X          float64 `json:"x"`
+		Y          float64 `json:"y"`
+		Width      float64 `json:"width"`
+		Height     float64 `json:"height"`
+		PageWidth  float64 `json:"pageWidth"`
+		PageHeight float64 `json:"pageHeight"`
+	}
+	if err := chromedp.Evaluate(fmt.Sprintf(elementBoundsJS, jsString(selector)), &bounds).Do(ctx); err != nil {
+		return nil, fmt.Errorf("定位元素失败: %w", err)
+	}
+	if bounds == nil {
+		return nil, fmt.Errorf("未找到元素: %s", selector)
+	}
+	if bounds.Width <= 0 || bounds.Height <= 0 {
+		return nil, fmt.Errorf("元素 %s 不可见（尺寸为 0）", selector)
+	}
 
-	// 执行截图
-	if req.FullPage {
-		tasks = append(tasks, chromedp.FullScreenshot(&buf, 100))
-	} else {
-		tasks = append(tasks, chromedp.CaptureScreenshot(&buf))
+	pad := float64(padding)
+	x0 := math.Max(bounds.X-pad, 0)
+	y0 := math.Max(bounds.Y-pad, 0)
+	x1 := math.Min(bounds.X+bounds.Width+pad, math.Max(bounds.PageWidth, bounds.X+bounds.Width))
+	y1 := math.Min(bounds.Y+bounds.Height+pad, math.Max(bounds.PageHeight, bounds.Y+bounds.Height))
+
+	return &page.Viewport{
+		X:      x0,
+		Y:      y0,
+		Width:  x1 - x0,
+		Height: y1 - y0,
+		Scale:  1,
+	}, nil
+}
+
+// WebP 转码的输入限制：图片以 base64 字符串整体通过 CDP 发送给浏览器，过大的图片会产生过大的消息
+const (
+	maxWebPInput     = 8 << 20 // PNG 数据的最大字节数
+	maxWebPDimension = 16383   // WebP 格式允许的最大宽高
+)
+
+// EncodeWebP 借助浏览器 canvas 将 PNG 图片转码为 WebP
+func (c *ChromeCapture) EncodeWebP(ctx context.Context, pngData []byte, quality int) ([]byte, error) {
+	if len(pngData) > maxWebPInput {
+		return nil, fmt.Errorf("图片过大（%d MB），超过 WebP 转码上限 %d MB，请改用 png 或 jpeg 格式",
+			len(pngData)>>20, maxWebPInput>>20)
+	}
+	if config, err := png.DecodeConfig(bytes.NewReader(pngData)); err != nil {
+		return nil, fmt.Errorf("读取图片尺寸失败: %w", err)
+	} else if config.Width > maxWebPDimension || config.Height > maxWebPDimension {
+		return nil, fmt.Errorf("图片尺寸 %dx%d 超过 WebP 格式上限 %d，请改用 png 或 jpeg 格式",
+			config.Width, config.Height, maxWebPDimension)
+	}
+
+	lease, err := c.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取浏览器失败: %w", err)
 	}
+	defer lease.Release()
+
+	taskCtx, cancel := lease.NewTab(ctx)
+	defer cancel()
+
+	encodeJS := fmt.Sprintf(`
+		new Promise((resolve, reject) => {
+			const img = new Image();
+			img.onload = () => {
+				const canvas = document.createElement('canvas');
+				canvas.width = img.naturalWidth;
+				canvas.height = img.naturalHeight;
+				canvas.getContext('2d').drawImage(img, 0, 0);
+				const url = canvas.toDataURL('image/webp', %f);
+				if (!url.startsWith('data:image/webp')) {
+					reject(new Error('浏览器不支持 WebP 编码'));
+					return;
+				}
+				resolve(url.substring(url.indexOf(',') + 1));
+			};
+			img.onerror = () => reject(new Error('加载图片失败'));
+			img.src = 'data:image/png;base64,%s';
+		})
+	`, float64(quality)/100, base64.StdEncoding.EncodeToString(pngData))
 
-	// 执行任务
-	if err := chromedp.Run(taskCtx, tasks); err != nil {
-		return nil, fmt.Errorf("截图失败: %w", err)
+	var encoded string
+	err = chromedp.Run(taskCtx,
+		chromedp.Navigate("about:blank"),
+		chromedp.Evaluate(encodeJS, &encoded, awaitPromise),
+	)
+	if err != nil {
+		lease.MarkFailed()
+		return nil, fmt.Errorf("WebP 编码失败: %w", err)
 	}
 
-	return buf, nil
+	return base64.StdEncoding.DecodeString(encoded)
 }
@@ -0,0 +1,223 @@
+package screenshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gotoailab/snapup/internal/models"
+)
+
+// maxDiffPixels 对比图片允许的最大像素数（两张图片宽高各取最大值的面积），避免差异图占用过多内存
+const maxDiffPixels = 50_000_000
+
+// normalizeDiff 验证对比参数并填充默认值，截取 URL 时的共用截图参数由 Validate 验证
+func normalizeDiff(req *models.DiffRequest) error {
+	for _, src := range []struct {
+		name string
+		models.DiffSource
+	}{{"before", req.Before}, {"after", req.After}} {
+		if (src.URL == "") == (src.Filename == "") {
+			return invalidRequest("%s 必须且只能指定 url 或 filename 之一", src.name)
+		}
+		if src.Filename != "" {
+			if err := checkImageName(src.Filename); err != nil {
+				return err
+			}
+		}
+	}
+	return normalizeDiffOptions(&req.Threshold, req.MaxMismatch)
+}
+
+// normalizeDiffOptions 验证颜色差异容差和允许的不同像素百分比，未指定容差时使用默认值
+func normalizeDiffOptions(threshold **float64, maxMismatch float64) error {
+	if *threshold == nil {
+		t := models.DefaultDiffThreshold
+		*threshold = &t
+	}
+	if t := **threshold; t < 0 || t > 1 {
+		return invalidRequest("颜色差异容差必须在 0-1 之间")
+	}
+	if maxMismatch < 0 || maxMismatch > 100 {
+		return invalidRequest("允许的不同像素百分比必须在 0-100 之间")
+	}
+	return nil
+}
+
+// Diff 逐像素比较两张截图并生成差异图。URL 来源先用共用的截图参数以 PNG 格式并发截图，
+// 文件名来源读取输出目录中已保存的截图。任一来源截图或读取失败时对比失败。
+func (s *Service) Diff(ctx context.Context, req models.DiffRequest) *models.DiffResponse {
+	start := time.Now()
+	fail := func(err error) *models.DiffResponse {
+		return &models.DiffResponse{
+			Success: false,
+			Message: err.Error(),
+			Code:    ErrorCode(err),
+			Total:   time.Since(start).Milliseconds(),
+		}
+	}
+	if err := normalizeDiff(&req); err != nil {
+		return fail(err)
+	}
+
+	sources := []models.DiffSource{req.Before, req.After}
+	names := [2]string{"before", "after"}
+	images := make([]*models.DiffImage, len(sources))
+	errs := make([]error, len(sources))
+
+	// 截图参数和 URL 访问策略在截图前统一检查，避免一侧截图完成后另一侧才被拒绝
+	shotReq := req.ScreenshotRequest
+	shotReq.Format = models.FormatPNG
+	for i, src := range sources {
+		if src.URL == "" {
+			continue
+		}
+		item := shotReq
+		item.URL = src.URL
+		if err := s.Validate(ctx, item); err != nil {
+			return fail(fmt.Errorf("%s: %w", names[i], err))
+		}
+	}
+
+	// 请求已被接受，两侧截图在客户端达到并发上限时排队等待，而不是一侧被拒绝
+	ctx = WithQueueWait(ctx)
+
+	var wg sync.WaitGroup
+	for i, src := range sources {
+		if src.URL == "" {
+			images[i] = &models.DiffImage{ImageURL: "/screenshots/" + src.Filename, Filename: src.Filename}
+			continue
+		}
+		item := shotReq
+		item.URL = src.URL
+		wg.Add(1)
+		go func(i int, item models.ScreenshotRequest) {
+			defer wg.Done()
+			resp, _ := s.TakeScreenshot(ctx, item)
+			if !resp.Success {
+				errs[i] = &requestError{code: resp.Code, msg: fmt.Sprintf("%s 截图失败: %s", names[i], resp.Message)}
+				return
+			}
+			images[i] = &models.DiffImage{URL: item.URL, ImageURL: resp.ImageURL, Filename: resp.Filename}
+		}(i, item)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return fail(err)
+		}
+	}
+
+	resp, err := s.compareFiles(ctx, images[0], images[1], DiffOptions{
+		Threshold: *req.Threshold,
+		IncludeAA: req.IncludeAA,
+	})
+	if err != nil {
+		return fail(err)
+	}
+	resp.Passed = resp.Mismatch <= req.MaxMismatch
+	resp.Total = time.Since(start).Milliseconds()
+	return resp
+}
+
+// compareFiles 比较输出目录中的两张截图，保存差异图并填充两张图片的尺寸
+func (s *Service) compareFiles(ctx context.Context, before, after *models.DiffImage, opts DiffOptions) (*models.DiffResponse, error) {
+	imgBefore, err := s.loadImage(before.Filename)
+	if err != nil {
+		return nil, err
+	}
+	imgAfter, err := s.loadImage(after.Filename)
+	if err != nil {
+		return nil, err
+	}
+	before.Width, before.Height = imgBefore.Bounds().Dx(), imgBefore.Bounds().Dy()
+	after.Width, after.Height = imgAfter.Bounds().Dx(), imgAfter.Bounds().Dy()
+	if max(before.Width, after.Width)*max(before.Height, after.Height) > maxDiffPixels {
+		return nil, invalidRequest("图片过大，对比区域不能超过 %d 像素", maxDiffPixels)
+	}
+
+	result := CompareImages(imgBefore, imgAfter, opts)
+	data, err := encodeImage(ctx, result.Image, models.FormatPNG, 0, nil)
+	if err != nil {
+		return nil, newPhaseError(ctx, PhaseProcessing, err)
+	}
+	filename := "diff_" + uuid.New().String() + ".png"
+	if err := s.save(ctx, filename, data); err != nil {
+		return nil, err
+	}
+
+	return &models.DiffResponse{
+		Success:      true,
+		Message:      "对比完成",
+		Mismatch:     result.Mismatch,
+		DiffPixels:   result.DiffPixels,
+		AAPixels:     result.AAPixels,
+		TotalPixels:  result.TotalPixels,
+		SizeMismatch: before.Width != after.Width || before.Height != after.Height,
+		ImageURL:     "/screenshots/" + filename,
+		Filename:     filename,
+		Width:        result.Image.Bounds().Dx(),
+		Height:       result.Image.Bounds().Dy(),
+		Before:       before,
+		After:        after,
+	}, nil
+}
+
+// checkImageName 检查输出目录中的截图文件名：不能包含路径，只能是 PNG 或 JPEG 格式
+func checkImageName(filename string) error {
+	if filename != filepath.Base(filename) || strings.ContainsAny(filename, `/\`) || strings.HasPrefix(filename, ".") {
+		return invalidRequest("无效的文件名: %s", filename)
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".png", ".jpg", ".jpeg":
+		return nil
+	default:
+		return invalidRequest("只能对比 PNG 或 JPEG 格式的截图: %s", filename)
+	}
+}
+
+// loadImage 读取并解码输出目录中的截图，只读取普通文件，不跟随符号链接
+func (s *Service) loadImage(filename string) (image.Image, error) {
+	if err := checkImageName(filename); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(s.outputDir, filename)
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && !info.Mode().IsRegular()) {
+		return nil, notFound("截图文件不存在: %s", filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取截图 %s 失败: %w", filename, err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取截图 %s 失败: %w", filename, err)
+	}
+	defer f.Close()
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, invalidRequest("无法解码截图 %s: %v", filename, err)
+	}
+	if config.Width*config.Height > maxDiffPixels {
+		return nil, invalidRequest("截图 %s 过大，不能超过 %d 像素", filename, maxDiffPixels)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, fmt.Errorf("读取截图 %s 失败: %w", filename, err)
+	}
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, invalidRequest("无法解码截图 %s: %v", filename, err)
+	}
+	return img, nil
+}
@@ -0,0 +1,438 @@
+package screenshot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gotoailab/snapup/internal/models"
+)
+
+// CompositeShot 合成图中单个设备的原始截图
+type CompositeShot struct {
+	Data   []byte
+	Device models.DeviceConfig
+}
+
+// CompositeOptions 合成图的布局和输出参数
+type CompositeOptions struct {
+	Layout      models.CompositeLayout
+	Spacing     int // 设备之间及画布四周的间距(像素)
+	Columns     int // grid 布局的列数，0 表示自动
+	CanvasWidth int // 输出图片宽度(像素)，0 表示自动
+	Background  string
+	Format      models.ImageFormat
+	Quality     int
+}
+
+// normalizeComposite 验证合成图的布局参数并填充默认值，各设备共用的截图参数由 validateRequest 验证
+func normalizeComposite(req *models.CompositeRequest) error {
+	if len(req.Devices) == 0 {
+		req.Devices = append([]models.DeviceType(nil), models.DefaultCompositeDevices...)
+	}
+	if len(req.Devices) > models.MaxCompositeDevices {
+		return invalidRequest("合成图最多 %d 个设备，当前 %d 个", models.MaxCompositeDevices, len(req.Devices))
+	}
+	seen := make(map[models.DeviceType]bool, len(req.Devices))
+	for _, device := range req.Devices {
+		if device == "" {
+			return invalidRequest("设备不能为空")
+		}
+		if seen[device] {
+			return invalidRequest("设备重复: %s", device)
+		}
+		seen[device] = true
+	}
+
+	switch req.Layout {
+	case "":
+		req.Layout = models.LayoutShowcase
+	case models.LayoutShowcase, models.LayoutGrid, models.LayoutRow:
+	default:
+		return invalidRequest("不支持的布局: %s", req.Layout)
+	}
+	if req.Spacing == nil {
+		spacing := models.DefaultCompositeSpace
+		req.Spacing = &spacing
+	}
+	if *req.Spacing < 0 || *req.Spacing > models.MaxCompositeSpace {
+		return invalidRequest("间距必须在 0-%d 之间", models.MaxCompositeSpace)
+	}
+	if req.Columns < 0 || req.Columns > len(req.Devices) {
+		return invalidRequest("列数必须在 0-%d 之间", len(req.Devices))
+	}
+	if req.CanvasWidth != 0 && (req.CanvasWidth < 2**req.Spacing+models.MinViewportSize || req.CanvasWidth > models.MaxCanvasWidth) {
+		return invalidRequest("画布宽度必须在 %d-%d 之间", 2**req.Spacing+models.MinViewportSize, models.MaxCanvasWidth)
+	}
+
+	if req.FullPage || req.Selector != "" || req.Clip != nil {
+		return invalidRequest("合成图不支持全页、元素或区域截图")
+	}
+	if req.HasViewportOverride() {
+		return invalidRequest("合成图不支持自定义视口，请改用设备预设")
+	}
+	return nil
+}
+
+// TakeComposite 在多个设备上并发截取同一个 URL，套用设备外框后按布局合成到一张图中。
+// 每个设备的截图各自计算超时时间，任一设备失败时整个合成图失败，结果中包含各设备的截图结果。
+func (s *Service) TakeComposite(ctx context.Context, req models.CompositeRequest) *models.CompositeResponse {
+	start := time.Now()
+	if err := normalizeComposite(&req); err != nil {
+		return &models.CompositeResponse{Success: false, Message: err.Error(), Code: ErrorCode(err)}
+	}
+
+	// 共用参数、设备和 URL 访问策略在截图前统一检查，避免无效请求占用截图名额
+	if err := s.validateRequest(&req.ScreenshotRequest); err != nil {
+		return &models.CompositeResponse{Success: false, Message: err.Error(), Code: ErrorCode(err)}
+	}
+	for _, device := range req.Devices {
+		if _, err := s.devices.Get(device); err != nil {
+			err = invalidRequest("%v", err)
+			return &models.CompositeResponse{Success: false, Message: err.Error(), Code: ErrorCode(err)}
+		}
+	}
+	if err := s.policy.Check(ctx, req.URL); err != nil {
+		return &models.CompositeResponse{Success: false, Message: err.Error(), Code: ErrorCode(err)}
+	}
+
+	// 请求已被接受，各设备截图在客户端达到并发上限时排队等待，而不是被拒绝
+	ctx = WithQueueWait(ctx)
+
+	var (
+		wg    sync.WaitGroup
+		sem   = make(chan struct{}, s.batchConcurrency())
+		items = make([]models.BatchItem, len(req.Devices))
+		shots = make([]CompositeShot, len(req.Devices))
+	)
+	for i, device := range req.Devices {
+		item := req.ScreenshotRequest
+		item.Device = device
+		item.Style = models.StyleDevice
+		items[i] = models.BatchItem{URL: item.URL, Device: device, Style: item.Style}
+
+		wg.Add(1)
+		go func(i int, item models.ScreenshotRequest) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				items[i].ScreenshotResponse = failure(fmt.Errorf("合成图已取消: %w", ctx.Err()))
+				return
+			}
+
+			itemCtx, cancel := s.withTimeout(ctx, item.Timeout)
+			defer cancel()
+			shot, resp := s.capture(itemCtx, item)
+			if resp != nil {
+				items[i].ScreenshotResponse = resp
+				return
+			}
+			// 外框和合成在所有设备截图完成后统一处理，截图完成即可归还名额
+			shot.release()
+
+			shots[i] = CompositeShot{Data: shot.result.Data, Device: shot.device}
+			timings := shot.result.Timings
+			timings.Total = time.Since(shot.start).Milliseconds()
+			items[i].ScreenshotResponse = &models.ScreenshotResponse{
+				Success: true,
+				Message: "截图成功",
+				Style:   item.Style,
+				Width:   shot.device.Width,
+				Height:  shot.device.Height,
+				Scale:   shot.device.Scale,
+				Page:    shot.result.Page,
+				Timings: &timings,
+				Queue:   shot.queue,
+			}
+		}(i, item)
+	}
+	wg.Wait()
+
+	resp := &models.CompositeResponse{Layout: req.Layout, Items: items}
+	fail := func(err error) *models.CompositeResponse {
+		resp.Success = false
+		resp.Message = err.Error()
+		resp.Code = ErrorCode(err)
+		resp.Total = time.Since(start).Milliseconds()
+		return resp
+	}
+	for _, item := range items {
+		if !item.Success {
+			resp.Success = false
+			resp.Message = fmt.Sprintf("设备 %s 截图失败: %s", item.Device, item.Message)
+			resp.Code = item.Code
+			resp.Total = time.Since(start).Milliseconds()
+			return resp
+		}
+	}
+
+	// 外框和合成与单张截图一样受请求超时限制
+	ctx, cancel := s.withTimeout(ctx, req.Timeout)
+	defer cancel()
+	data, size, err := s.processor.Composite(ctx, shots, CompositeOptions{
+		Layout:      req.Layout,
+		Spacing:     *req.Spacing,
+		Columns:     req.Columns,
+		CanvasWidth: req.CanvasWidth,
+		Background:  req.Background,
+		Format:      req.Format,
+		Quality:     req.Quality,
+	})
+	if err != nil {
+		return fail(newPhaseError(ctx, PhaseProcessing, err))
+	}
+
+	filename := fmt.Sprintf("composite_%s_%s%s", req.Layout, uuid.New().String(), req.Format.Extension())
+	if err := s.save(ctx, filename, data); err != nil {
+		return fail(err)
+	}
+
+	resp.Success = true
+	resp.Message = "合成图生成成功"
+	resp.ImageURL = "/screenshots/" + filename
+	resp.Filename = filename
+	resp.Format = req.Format
+	resp.Width = size.X
+	resp.Height = size.Y
+	resp.Total = time.Since(start).Milliseconds()
+	return resp
+}
+
+// Composite 为每个设备的截图套用外框，按布局绘制到同一张画布上并编码，返回图片数据和画布尺寸
+func (p *ImageProcessor) Composite(ctx context.Context, shots []CompositeShot, opts CompositeOptions) ([]byte, image.Point, error) {
+	framed := make([]*image.RGBA, len(shots))
+	sizes := make([]compositeSize, len(shots))
+	for i, shot := range shots {
+		if err := ctx.Err(); err != nil {
+			return nil, image.Point{}, err
+		}
+		img, _, err := image.Decode(bytes.NewReader(shot.Data))
+		if err != nil {
+			return nil, image.Point{}, fmt.Errorf("解码 %s 截图失败: %w", shot.Device.Name, err)
+		}
+		framed[i] = renderDeviceFrame(img, p.frameFor(shot.Device))
+
+		scale := shot.Device.Scale
+		if scale <= 0 {
+			scale = 1
+		}
+		b := framed[i].Bounds()
+		sizes[i] = compositeSize{w: float64(b.Dx()) / scale, h: float64(b.Dy()) / scale, scale: scale}
+	}
+
+	placements, canvas := layoutComposite(sizes, opts)
+	result := image.NewRGBA(image.Rectangle{Max: canvas})
+	bg := p.parseColor(opts.Background, color.RGBA{R: 240, G: 242, B: 245, A: 255})
+	draw.Draw(result, result.Bounds(), &image.Uniform{bg}, image.Point{}, draw.Src)
+
+	// 先画的设备在后方，每个设备带有与 device 样式一致的投影
+	for _, pl := range placements {
+		if err := ctx.Err(); err != nil {
+			return nil, image.Point{}, err
+		}
+		img := resizeImage(framed[pl.index], pl.rect.Dx(), pl.rect.Dy())
+		padding := math.Max(8, float64(pl.rect.Dx())*0.06)
+		drawMaskShadow(result, alphaMask(img), pl.rect.Min, ShadowOptions{
+			Blur:    padding * 0.6,
+			OffsetY: int(padding / 4),
+			Color:   color.RGBA{A: 255},
+			Opacity: 0.22,
+		})
+		draw.Draw(result, pl.rect, img, image.Point{}, draw.Over)
+	}
+
+	data, err := encodeImage(ctx, result, opts.Format, opts.Quality, p.webp)
+	if err != nil {
+		return nil, image.Point{}, err
+	}
+	return data, canvas, nil
+}
+
+// compositeSize 套用外框后的设备尺寸(CSS 像素)，按 CSS 像素排版才能体现设备之间的真实比例
+type compositeSize struct {
+	w, h  float64
+	scale float64 // 设备像素比，即原图相对 CSS 像素的最大清晰倍数
+}
+
+// unitRect 以 CSS 像素表示的矩形
+type unitRect struct {
+	x, y, w, h float64
+}
+
+// unitLayout 以 CSS 像素排版的结果。间距不随画布缩放，单独记录每个设备左侧和上方的间距数量
+type unitLayout struct {
+	rects        []unitRect
+	gapX, gapY   []int // 每个设备左侧、上方的间距数量（含画布边距）
+	hGaps, vGaps int   // 画布宽、高包含的间距数量
+	order        []int // 绘制顺序，先画的在后方
+}
+
+// compositePlacement 设备在画布中的位置
+type compositePlacement struct {
+	index int
+	rect  image.Rectangle
+}
+
+// layoutComposite 计算各设备在画布中的位置（按绘制顺序）和画布尺寸。
+// 先按 CSS 像素排版，再按画布宽度求出统一的缩放倍数；未指定画布宽度时不放大，超过默认宽度时缩小。
+func layoutComposite(sizes []compositeSize, opts CompositeOptions) ([]compositePlacement, image.Point) {
+	// 面积大的设备排在前面
+	bySize := make([]int, len(sizes))
+	for i := range bySize {
+		bySize[i] = i
+	}
+	sort.SliceStable(bySize, func(a, b int) bool {
+		return sizes[bySize[a]].w*sizes[bySize[a]].h > sizes[bySize[b]].w*sizes[bySize[b]].h
+	})
+
+	var l unitLayout
+	switch opts.Layout {
+	case models.LayoutRow:
+		l = layoutRow(sizes, bySize)
+	case models.LayoutGrid:
+		l = layoutGrid(sizes, opts.Columns)
+	default:
+		l = layoutShowcase(sizes, bySize)
+	}
+
+	var width, height float64
+	for _, r := range l.rects {
+		width = math.Max(width, r.x+r.w)
+		height = math.Max(height, r.y+r.h)
+	}
+
+	spacing := float64(opts.Spacing)
+	k := 1.0
+	switch {
+	case opts.CanvasWidth > 0:
+		k = (float64(opts.CanvasWidth) - spacing*float64(l.hGaps)) / width
+	case width+spacing*float64(l.hGaps) > models.DefaultCanvasWidth:
+		k = (models.DefaultCanvasWidth - spacing*float64(l.hGaps)) / width
+	}
+	canvas := image.Pt(
+		int(math.Round(width*k+spacing*float64(l.hGaps))),
+		int(math.Round(height*k+spacing*float64(l.vGaps))),
+	)
+
+	placements := make([]compositePlacement, 0, len(l.order))
+	for _, i := range l.order {
+		r := l.rects[i]
+		dx, dy := spacing*float64(l.gapX[i]), spacing*float64(l.gapY[i])
+		x0, y0 := int(math.Round(r.x*k+dx)), int(math.Round(r.y*k+dy))
+		x1, y1 := int(math.Round((r.x+r.w)*k+dx)), int(math.Round((r.y+r.h)*k+dy))
+		placements = append(placements, compositePlacement{
+			index: i,
+			rect:  image.Rect(x0, y0, max(x1, x0+1), max(y1, y0+1)),
+		})
+	}
+	return placements, canvas
+}
+
+// layoutRow 从大到小排成一行，底部对齐
+func layoutRow(sizes []compositeSize, bySize []int) unitLayout {
+	l := unitLayout{
+		rects: make([]unitRect, len(sizes)),
+		gapX:  make([]int, len(sizes)),
+		gapY:  make([]int, len(sizes)),
+		hGaps: len(sizes) + 1,
+		vGaps: 2,
+		order: bySize,
+	}
+	var height float64
+	for _, s := range sizes {
+		height = math.Max(height, s.h)
+	}
+	var x float64
+	for n, i := range bySize {
+		l.rects[i] = unitRect{x: x, y: height - sizes[i].h, w: sizes[i].w, h: sizes[i].h}
+		l.gapX[i], l.gapY[i] = n+1, 1
+		x += sizes[i].w
+	}
+	return l
+}
+
+// layoutGrid 排成等大的网格，按请求中的设备顺序从左到右、从上到下排列。
+// 每个设备等比缩放后在单元格内居中，放大倍数不超过设备像素比，避免图片模糊。
+func layoutGrid(sizes []compositeSize, columns int) unitLayout {
+	if columns <= 0 {
+		columns = int(math.Ceil(math.Sqrt(float64(len(sizes)))))
+	}
+	rows := (len(sizes) + columns - 1) / columns
+	l := unitLayout{
+		rects: make([]unitRect, len(sizes)),
+		gapX:  make([]int, len(sizes)),
+		gapY:  make([]int, len(sizes)),
+		hGaps: columns + 1,
+		vGaps: rows + 1,
+		order: make([]int, len(sizes)),
+	}
+
+	var cellW, cellH float64
+	for _, s := range sizes {
+		cellW = math.Max(cellW, s.w)
+		cellH = math.Max(cellH, s.h)
+	}
+	for i, s := range sizes {
+		fit := math.Min(math.Min(cellW/s.w, cellH/s.h), s.scale)
+		w, h := s.w*fit, s.h*fit
+		col, row := i%columns, i/columns
+		l.rects[i] = unitRect{x: float64(col)*cellW + (cellW-w)/2, y: float64(row)*cellH + (cellH-h)/2, w: w, h: h}
+		l.gapX[i], l.gapY[i] = col+1, row+1
+		l.order[i] = i
+	}
+	return l
+}
+
+// layoutShowcase 最大的设备居中放在后方，其余设备从大到小交替放在右侧和左侧，
+// 与内侧的设备重叠一半宽度，底部略低于后方设备以形成前后层次
+func layoutShowcase(sizes []compositeSize, bySize []int) unitLayout {
+	l := unitLayout{
+		rects: make([]unitRect, len(sizes)),
+		gapX:  make([]int, len(sizes)),
+		gapY:  make([]int, len(sizes)),
+		hGaps: 2,
+		vGaps: 2,
+		order: bySize,
+	}
+	back := bySize[0]
+	l.rects[back] = unitRect{w: sizes[back].w, h: sizes[back].h}
+	bottom := sizes[back].h * 1.06
+
+	left, right := 0.0, sizes[back].w
+	for n, i := range bySize[1:] {
+		s := sizes[i]
+		r := unitRect{y: bottom - s.h, w: s.w, h: s.h}
+		if n%2 == 0 {
+			r.x = right - s.w/2
+			right = r.x + s.w
+		} else {
+			r.x = left - s.w/2
+			left = r.x
+		}
+		l.rects[i] = r
+	}
+
+	// 平移到原点，所有设备只偏移画布四周的边距
+	var minX, minY float64
+	for _, r := range l.rects {
+		minX = math.Min(minX, r.x)
+		minY = math.Min(minY, r.y)
+	}
+	for i := range l.rects {
+		l.rects[i].x -= minX
+		l.rects[i].y -= minY
+		l.gapX[i], l.gapY[i] = 1, 1
+	}
+	return l
+}
@@ -0,0 +1,93 @@
+package screenshot
+
+import (
+	"image"
+	"reflect"
+	"testing"
+
+	"github.com/gotoailab/snapup/internal/models"
+)
+
+func TestLayoutComposite(t *testing.T) {
+	big := compositeSize{w: 100, h: 200, scale: 1}
+	small := compositeSize{w: 50, h: 100, scale: 1}
+
+	tests := []struct {
+		name       string
+		sizes      []compositeSize
+		opts       CompositeOptions
+		placements []compositePlacement
+		canvas     image.Point
+	}{
+		{
+			name:  "row 从大到小排列、底部对齐",
+			sizes: []compositeSize{small, big},
+			opts:  CompositeOptions{Layout: models.LayoutRow, Spacing: 10},
+			placements: []compositePlacement{
+				{index: 1, rect: image.Rect(10, 10, 110, 210)},
+				{index: 0, rect: image.Rect(120, 110, 170, 210)},
+			},
+			canvas: image.Pt(180, 220),
+		},
+		{
+			name:  "row 按画布宽度缩放，间距不缩放",
+			sizes: []compositeSize{big, small},
+			opts:  CompositeOptions{Layout: models.LayoutRow, Spacing: 10, CanvasWidth: 360},
+			placements: []compositePlacement{
+				{index: 0, rect: image.Rect(10, 10, 230, 450)},
+				{index: 1, rect: image.Rect(240, 230, 350, 450)},
+			},
+			canvas: image.Pt(360, 460),
+		},
+		{
+			name:  "超过默认宽度时缩小",
+			sizes: []compositeSize{{w: 6400, h: 100, scale: 1}},
+			opts:  CompositeOptions{Layout: models.LayoutRow},
+			placements: []compositePlacement{
+				{index: 0, rect: image.Rect(0, 0, 3200, 50)},
+			},
+			canvas: image.Pt(3200, 50),
+		},
+		{
+			name:  "grid 在单元格内居中，不超过设备像素比放大",
+			sizes: []compositeSize{{w: 50, h: 50, scale: 1}, {w: 100, h: 100, scale: 1}},
+			opts:  CompositeOptions{Layout: models.LayoutGrid, Columns: 2},
+			placements: []compositePlacement{
+				{index: 0, rect: image.Rect(25, 25, 75, 75)},
+				{index: 1, rect: image.Rect(100, 0, 200, 100)},
+			},
+			canvas: image.Pt(200, 100),
+		},
+		{
+			name:  "grid 按设备像素比放大填满单元格",
+			sizes: []compositeSize{{w: 100, h: 100, scale: 1}, {w: 50, h: 50, scale: 2}},
+			opts:  CompositeOptions{Layout: models.LayoutGrid, Columns: 2, Spacing: 10},
+			placements: []compositePlacement{
+				{index: 0, rect: image.Rect(10, 10, 110, 110)},
+				{index: 1, rect: image.Rect(120, 10, 220, 110)},
+			},
+			canvas: image.Pt(230, 120),
+		},
+		{
+			name:  "showcase 小设备在右前方重叠一半宽度",
+			sizes: []compositeSize{big, small},
+			opts:  CompositeOptions{Layout: models.LayoutShowcase, Spacing: 10},
+			placements: []compositePlacement{
+				{index: 0, rect: image.Rect(10, 10, 110, 210)},
+				{index: 1, rect: image.Rect(85, 122, 135, 222)},
+			},
+			canvas: image.Pt(145, 232),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			placements, canvas := layoutComposite(tt.sizes, tt.opts)
+			if canvas != tt.canvas {
+				t.Errorf("canvas = %v, want %v", canvas, tt.canvas)
+			}
+			if !reflect.DeepEqual(placements, tt.placements) {
+				t.Errorf("placements = %v, want %v", placements, tt.placements)
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package screenshot
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gotoailab/snapup/internal/models"
+)
+
+type linkCollectorKey struct{}
+
+// withLinkCollector 在 context 中注册链接回调，截图时以页面中所有链接的绝对地址调用
+func withLinkCollector(ctx context.Context, collect func(links []string)) context.Context {
+	return context.WithValue(ctx, linkCollectorKey{}, collect)
+}
+
+// linkCollectorFromContext 返回注册的链接回调，未注册时返回 nil
+func linkCollectorFromContext(ctx context.Context) func([]string) {
+	collect, _ := ctx.Value(linkCollectorKey{}).(func([]string))
+	return collect
+}
+
+// skippedExtensions 不是网页的链接，爬取时跳过
+var skippedExtensions = map[string]bool{
+	".pdf": true, ".zip": true, ".gz": true, ".tar": true, ".rar": true, ".7z": true,
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true, ".svg": true, ".ico": true,
+	".mp3": true, ".mp4": true, ".webm": true, ".mov": true, ".avi": true,
+	".css": true, ".js": true, ".json": true, ".xml": true, ".txt": true,
+	".doc": true, ".docx": true, ".xls": true, ".xlsx": true, ".ppt": true, ".pptx": true,
+	".exe": true, ".dmg": true, ".apk": true,
+}
+
+// crawl 一次站点爬取的状态
+type crawl struct {
+	req      models.CrawlRequest
+	root     *url.URL
+	sitemap  bool
+	maxDepth int
+	maxPages int
+	include  []*regexp.Regexp
+	exclude  []*regexp.Regexp
+
+	seen    map[string]bool // 发现的页面
+	visited map[string]bool // 跟随重定向后实际截图的地址
+}
+
+// newCrawl 验证爬取请求并填充默认值
+func (s *Service) newCrawl(ctx context.Context, req models.CrawlRequest) (*crawl, error) {
+	if err := s.Validate(ctx, req.ScreenshotRequest); err != nil {
+		return nil, err
+	}
+	root, err := url.Parse(req.URL)
+	if err != nil || (root.Scheme != "http" && root.Scheme != "https") {
+		return nil, invalidURL("爬取的起始地址必须是 http 或 https URL: %s", req.URL)
+	}
+
+	c := &crawl{
+		req:      req,
+		root:     root,
+		sitemap:  req.Sitemap || strings.EqualFold(path.Ext(root.Path), ".xml"),
+		maxDepth: models.DefaultCrawlDepth,
+		maxPages: req.MaxPages,
+		seen:     make(map[string]bool),
+		visited:  make(map[string]bool),
+	}
+	if req.MaxDepth != nil {
+		c.maxDepth = *req.MaxDepth
+	}
+	if c.maxDepth < 0 || c.maxDepth > models.MaxCrawlDepth {
+		return nil, invalidRequest("爬取深度必须在 0-%d 之间", models.MaxCrawlDepth)
+	}
+	if c.maxPages == 0 {
+		c.maxPages = min(models.DefaultCrawlPages, s.maxCrawlPages)
+	}
+	if c.maxPages < 1 || c.maxPages > s.maxCrawlPages {
+		return nil, invalidRequest("爬取页面数必须在 1-%d 之间", s.maxCrawlPages)
+	}
+	for _, pattern := range req.Include {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, invalidRequest("include 规则 %q 无效: %v", pattern, err)
+		}
+		c.include = append(c.include, re)
+	}
+	for _, pattern := range req.Exclude {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, invalidRequest("exclude 规则 %q 无效: %v", pattern, err)
+		}
+		c.exclude = append(c.exclude, re)
+	}
+	return c, nil
+}
+
+// ValidateCrawl 检查爬取请求，不执行爬取
+func (s *Service) ValidateCrawl(ctx context.Context, req models.CrawlRequest) error {
+	_, err := s.newCrawl(ctx, req)
+	return err
+}
+
+// crawlTarget 待截图的页面
+type crawlTarget struct {
+	url    string
+	parent string
+}
+
+// Crawl 爬取站点并逐个截图。从起始页面或 sitemap 中列出的页面出发，按层跟随同源链接，
+// 直到达到最大深度或页面数；同一层的页面并发截图，并发数不超过每个客户端的上限。
+// 完成后在输出目录写入 JSON 索引和 HTML 图集。progress 不为空时在每个页面完成后调用。
+func (s *Service) Crawl(ctx context.Context, req models.CrawlRequest, progress func(done, total int)) *models.CrawlResponse {
+	resp := &models.CrawlResponse{Root: req.URL, Pages: []models.CrawlPage{}}
+	fail := func(err error) *models.CrawlResponse {
+		resp.Status = models.BatchFailed
+		resp.Message = err.Error()
+		resp.Code = ErrorCode(err)
+		return resp
+	}
+
+	c, err := s.newCrawl(ctx, req)
+	if err != nil {
+		return fail(err)
+	}
+
+	// 起始页面
+	var frontier []crawlTarget
+	if c.sitemap {
+		locs, err := s.fetchSitemap(ctx, c.root.String())
+		if err != nil {
+			return fail(err)
+		}
+		for _, loc := range locs {
+			if key, ok := c.accept(loc, false); ok {
+				frontier = append(frontier, crawlTarget{url: key})
+			}
+		}
+	} else if key, ok := c.accept(c.root.String(), true); ok {
+		frontier = append(frontier, crawlTarget{url: key})
+	}
+
+	var (
+		mu   sync.Mutex
+		done int
+		sem  = make(chan struct{}, s.batchConcurrency())
+	)
+	report := func() {
+		if progress != nil {
+			progress(done, min(len(c.seen), c.maxPages))
+		}
+	}
+	report()
+
+	for depth := 0; len(frontier) > 0 && ctx.Err() == nil; depth++ {
+		if room := c.maxPages - len(resp.Pages); len(frontier) > room {
+			frontier = frontier[:room]
+		}
+		pages := make([]models.CrawlPage, len(frontier))
+		links := make([][]string, len(frontier))
+
+		var wg sync.WaitGroup
+		for i, target := range frontier {
+			wg.Add(1)
+			go func(i int, target crawlTarget) {
+				defer wg.Done()
+
+				item := c.req.ScreenshotRequest
+				item.URL = target.url
+				pageCtx := ctx
+				if depth < c.maxDepth {
+					pageCtx = withLinkCollector(ctx, func(found []string) { links[i] = found })
+				}
+
+				var result *models.ScreenshotResponse
+				select {
+				case sem <- struct{}{}:
+					result, _ = s.TakeScreenshot(pageCtx, item)
+					<-sem
+				case <-ctx.Done():
+					result = &models.ScreenshotResponse{Success: false, Message: "爬取已取消", Code: ErrorCode(ctx.Err())}
+				}
+				pages[i] = models.CrawlPage{URL: target.url, Depth: depth, Parent: target.parent, ScreenshotResponse: result}
+
+				mu.Lock()
+				done++
+				report()
+				mu.Unlock()
+			}(i, target)
+		}
+		wg.Wait()
+		resp.Pages = append(resp.Pages, pages...)
+
+		// 起始页面重定向到其他地址（如 http 跳转到 https、裸域名跳转到 www）时，以跳转后的地址判断同源
+		if depth == 0 && !c.sitemap && pages[0].Success && pages[0].Page != nil {
+			if final, err := url.Parse(pages[0].Page.FinalURL); err == nil && (final.Scheme == "http" || final.Scheme == "https") {
+				c.root = final
+			}
+		}
+
+		// 按页面顺序和链接在页面中的顺序收集下一层，保证结果稳定
+		var next []crawlTarget
+		for i, page := range pages {
+			if page.Success && page.Page != nil {
+				// 跟随重定向后的地址视为已访问，避免重复截图
+				c.visited[normalizeLink(page.Page.FinalURL)] = true
+			}
+			for _, link := range links[i] {
+				if key, ok := c.accept(link, false); ok {
+					next = append(next, crawlTarget{url: key, parent: page.URL})
+				}
+			}
+		}
+		frontier = next
+		mu.Lock()
+		report()
+		mu.Unlock()
+	}
+
+	resp.Total = len(resp.Pages)
+	resp.Discovered = len(c.seen)
+	resp.Truncated = resp.Discovered > resp.Total && ctx.Err() == nil
+	for _, page := range resp.Pages {
+		if page.Success {
+			resp.Succeeded++
+		} else {
+			resp.Failed++
+		}
+	}
+	switch {
+	case resp.Total > 0 && resp.Failed == 0:
+		resp.Status = models.BatchSuccess
+	case resp.Succeeded == 0:
+		resp.Status = models.BatchFailed
+	default:
+		resp.Status = models.BatchPartial
+	}
+	if resp.Total == 0 && resp.Message == "" {
+		resp.Message = "没有找到可截图的页面"
+	}
+
+	if err := s.writeCrawlIndex(ctx, resp); err != nil {
+		resp.Message = "写入爬取索引失败: " + err.Error()
+	}
+	return resp
+}
+
+// accept 规范化链接并判断是否需要截图：只接受与起始地址同源、不是文件且未访问过的链接，
+// 非起始页面还需通过 include 和 exclude 规则。接受的链接记为已发现，返回规范化后的地址。
+func (c *crawl) accept(link string, root bool) (string, bool) {
+	u, err := url.Parse(link)
+	if err != nil || !strings.EqualFold(u.Scheme, c.root.Scheme) || !strings.EqualFold(u.Host, c.root.Host) {
+		return "", false
+	}
+	key := normalizeLink(link)
+	if c.seen[key] || c.visited[key] {
+		return "", false
+	}
+	if !root {
+		if skippedExtensions[strings.ToLower(path.Ext(u.Path))] || !c.matches(key) {
+			return "", false
+		}
+	}
+	c.seen[key] = true
+	return key, true
+}
+
+// normalizeLink 去掉锚点并统一协议和主机的大小写，无法解析时原样返回
+func normalizeLink(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment, u.RawFragment = "", ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String()
+}
+
+// matches 按 include 和 exclude 规则判断链接是否需要截图
+func (c *crawl) matches(link string) bool {
+	for _, re := range c.exclude {
+		if re.MatchString(link) {
+			return false
+		}
+	}
+	if len(c.include) == 0 {
+		return true
+	}
+	for _, re := range c.include {
+		if re.MatchString(link) {
+			return true
+		}
+	}
+	return false
+}
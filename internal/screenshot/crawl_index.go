@@ -0,0 +1,88 @@
+package screenshot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"html/template"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gotoailab/snapup/internal/models"
+)
+
+// crawlGalleryTemplate 爬取结果的 HTML 图集。图片使用相对路径，
+// 通过 /screenshots/ 访问和直接打开输出目录中的文件都能显示。
+var crawlGalleryTemplate = template.Must(template.New("gallery").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>爬取截图 - {{.Root}}</title>
+<style>
+  body { margin: 0; padding: 32px; font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; background: #f0f2f5; color: #1f2937; }
+  h1 { margin: 0 0 8px; font-size: 22px; word-break: break-all; }
+  .summary { margin: 0 0 24px; color: #6b7280; font-size: 14px; }
+  .grid { display: grid; grid-template-columns: repeat(auto-fill, minmax(280px, 1fr)); gap: 20px; }
+  .card { background: #fff; border-radius: 10px; overflow: hidden; box-shadow: 0 2px 8px rgba(0, 0, 0, .08); }
+  .card img { display: block; width: 100%; height: 200px; object-fit: cover; object-position: top; background: #e5e7eb; }
+  .card .error { height: 200px; display: flex; align-items: center; justify-content: center; padding: 16px; box-sizing: border-box; background: #fef2f2; color: #b91c1c; font-size: 13px; text-align: center; word-break: break-all; }
+  .card .meta { padding: 12px 14px; font-size: 13px; }
+  .card .title { font-weight: 600; margin-bottom: 4px; white-space: nowrap; overflow: hidden; text-overflow: ellipsis; }
+  .card a.url { color: #4f46e5; text-decoration: none; word-break: break-all; }
+  .card .info { margin-top: 6px; color: #9ca3af; }
+</style>
+</head>
+<body>
+<h1>{{.Root}}</h1>
+<p class="summary">生成于 {{.Generated}} · 共 {{.Total}} 个页面，成功 {{.Succeeded}}，失败 {{.Failed}}{{if .Truncated}} · 发现 {{.Discovered}} 个页面，已达到页面数上限{{end}}</p>
+<div class="grid">
+{{- range .Pages}}
+  <div class="card">
+    {{- if .Success}}
+    <a href="{{.Filename}}" target="_blank"><img src="{{.Filename}}" loading="lazy" alt="{{.URL}}"></a>
+    {{- else}}
+    <div class="error">[{{.Code}}] {{.Message}}</div>
+    {{- end}}
+    <div class="meta">
+      <div class="title">{{with .Page}}{{.Title}}{{end}}</div>
+      <a class="url" href="{{.URL}}" target="_blank" rel="noopener noreferrer">{{.URL}}</a>
+      <div class="info">深度 {{.Depth}}{{with .Page}}{{if .Status}} · HTTP {{.Status}}{{end}}{{end}}</div>
+    </div>
+  </div>
+{{- end}}
+</div>
+</body>
+</html>
+`))
+
+// writeCrawlIndex 在输出目录写入爬取结果的 JSON 索引和 HTML 图集，并在结果中记录访问地址
+func (s *Service) writeCrawlIndex(ctx context.Context, resp *models.CrawlResponse) error {
+	name := "crawl_" + uuid.New().String()
+	resp.IndexURL = "/screenshots/" + name + ".html"
+	resp.IndexJSON = "/screenshots/" + name + ".json"
+
+	var html bytes.Buffer
+	err := crawlGalleryTemplate.Execute(&html, struct {
+		*models.CrawlResponse
+		Generated string
+	}{resp, time.Now().Format("2006-01-02 15:04:05")})
+	if err == nil {
+		err = s.save(ctx, name+".html", html.Bytes())
+	}
+	if err != nil {
+		resp.IndexURL, resp.IndexJSON = "", ""
+		return err
+	}
+
+	data, err := json.MarshalIndent(resp, "", "  ")
+	if err == nil {
+		err = s.save(ctx, name+".json", data)
+	}
+	if err != nil {
+		resp.IndexJSON = ""
+		return err
+	}
+	return nil
+}
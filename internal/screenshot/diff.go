@@ -0,0 +1,199 @@
+package screenshot
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// 差异图中的标记颜色
+var (
+	diffColor      = color.RGBA{R: 255, A: 255}               // 不同的像素
+	diffAAColor    = color.RGBA{R: 255, G: 200, A: 255}       // 抗锯齿造成的差异，不计入不同像素
+	diffExtraColor = color.RGBA{R: 200, G: 0, B: 255, A: 255} // 只存在于其中一张图片中的区域
+)
+
+// maxYIQDelta YIQ 色彩空间中两个颜色的最大差异值，容差按此比例换算
+const maxYIQDelta = 35215
+
+// DiffOptions 图片比较参数
+type DiffOptions struct {
+	Threshold float64 // 单个像素的颜色差异容差，0-1，越大越宽松
+	IncludeAA bool    // 是否把抗锯齿造成的差异也计为不同像素
+}
+
+// DiffResult 图片比较结果
+type DiffResult struct {
+	Image       *image.RGBA // 差异图：相同的像素淡化为灰度，不同的像素标红
+	DiffPixels  int         // 不同的像素数，包括尺寸不同时多出的区域
+	AAPixels    int         // 因抗锯齿而忽略的像素数
+	TotalPixels int         // 比较的像素总数，即两张图片宽高各取最大值的面积
+	Mismatch    float64     // 不同像素所占的百分比
+}
+
+// CompareImages 逐像素比较两张图片。颜色差异在 YIQ 色彩空间中按人眼感知计算，
+// 超过容差且不是抗锯齿边缘的像素计为不同。尺寸不同时按左上角对齐，
+// 只存在于其中一张图片中的区域全部计为不同，并在差异图中用紫色标出。
+func CompareImages(a, b image.Image, opts DiffOptions) *DiffResult {
+	imgA, imgB := toRGBA(a), toRGBA(b)
+	wa, ha := imgA.Bounds().Dx(), imgA.Bounds().Dy()
+	wb, hb := imgB.Bounds().Dx(), imgB.Bounds().Dy()
+	width, height := max(wa, wb), max(ha, hb)
+
+	result := &DiffResult{
+		Image:       image.NewRGBA(image.Rect(0, 0, width, height)),
+		TotalPixels: width * height,
+	}
+	maxDelta := maxYIQDelta * opts.Threshold * opts.Threshold
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if x >= wa || y >= ha || x >= wb || y >= hb {
+				result.Image.SetRGBA(x, y, diffExtraColor)
+				result.DiffPixels++
+				continue
+			}
+
+			delta := colorDelta(imgA, imgB, x, y, x, y, false)
+			switch {
+			case math.Abs(delta) <= maxDelta:
+				// 相同的像素以淡化的灰度显示，作为差异的参照
+				gray := blendWhite(yiqBrightness(pixelAt(imgA, x, y)), 0.1)
+				result.Image.SetRGBA(x, y, color.RGBA{R: gray, G: gray, B: gray, A: 255})
+			case !opts.IncludeAA && (antialiased(imgA, imgB, x, y) || antialiased(imgB, imgA, x, y)):
+				result.Image.SetRGBA(x, y, diffAAColor)
+				result.AAPixels++
+			default:
+				result.Image.SetRGBA(x, y, diffColor)
+				result.DiffPixels++
+			}
+		}
+	}
+
+	if result.TotalPixels > 0 {
+		result.Mismatch = float64(result.DiffPixels) * 100 / float64(result.TotalPixels)
+	}
+	return result
+}
+
+// antialiased 判断 img 中 (x, y) 处的像素是否为抗锯齿边缘：周围像素中既有更亮的也有更暗的，
+// 且最亮或最暗的相邻像素在两张图片中都位于大片同色区域内（即该像素是两种颜色之间的过渡）。
+func antialiased(img, other *image.RGBA, x, y int) bool {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	x0, y0 := max(x-1, 0), max(y-1, 0)
+	x1, y1 := min(x+1, w-1), min(y+1, h-1)
+	zeroes := 0
+	if x0 == x || x1 == x || y0 == y || y1 == y {
+		// 位于图片边缘时缺少的相邻像素视为相同
+		zeroes = 1
+	}
+
+	var minDelta, maxDelta float64
+	var minX, minY, maxX, maxY int
+	for ny := y0; ny <= y1; ny++ {
+		for nx := x0; nx <= x1; nx++ {
+			if nx == x && ny == y {
+				continue
+			}
+			delta := colorDelta(img, img, x, y, nx, ny, true)
+			switch {
+			case delta == 0:
+				zeroes++
+				if zeroes > 2 {
+					return false
+				}
+			case delta < minDelta:
+				minDelta, minX, minY = delta, nx, ny
+			case delta > maxDelta:
+				maxDelta, maxX, maxY = delta, nx, ny
+			}
+		}
+	}
+	if minDelta == 0 || maxDelta == 0 {
+		return false
+	}
+
+	return (hasManySiblings(img, minX, minY) && hasManySiblings(other, minX, minY)) ||
+		(hasManySiblings(img, maxX, maxY) && hasManySiblings(other, maxX, maxY))
+}
+
+// hasManySiblings 判断 (x, y) 周围是否有至少 3 个颜色完全相同的像素
+func hasManySiblings(img *image.RGBA, x, y int) bool {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if x >= w || y >= h {
+		return false
+	}
+	x0, y0 := max(x-1, 0), max(y-1, 0)
+	x1, y1 := min(x+1, w-1), min(y+1, h-1)
+	zeroes := 0
+	if x0 == x || x1 == x || y0 == y || y1 == y {
+		zeroes = 1
+	}
+
+	c := pixelAt(img, x, y)
+	for ny := y0; ny <= y1; ny++ {
+		for nx := x0; nx <= x1; nx++ {
+			if nx == x && ny == y {
+				continue
+			}
+			if pixelAt(img, nx, ny) == c {
+				zeroes++
+				if zeroes > 2 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// colorDelta 计算 a 中 (ax, ay) 与 b 中 (bx, by) 两个像素在 YIQ 色彩空间中的差异，
+// 半透明像素先与白色混合。yOnly 为 true 时只比较亮度。b 更亮时返回正数，更暗时返回负数。
+func colorDelta(a, b *image.RGBA, ax, ay, bx, by int, yOnly bool) float64 {
+	ca, cb := pixelAt(a, ax, ay), pixelAt(b, bx, by)
+	if ca == cb {
+		return 0
+	}
+
+	r1, g1, b1 := blendAlpha(ca)
+	r2, g2, b2 := blendAlpha(cb)
+	y1, y2 := rgbToY(r1, g1, b1), rgbToY(r2, g2, b2)
+	dy := y1 - y2
+	if yOnly {
+		return dy
+	}
+
+	di := rgbToI(r1, g1, b1) - rgbToI(r2, g2, b2)
+	dq := rgbToQ(r1, g1, b1) - rgbToQ(r2, g2, b2)
+	delta := 0.5053*dy*dy + 0.299*di*di + 0.1957*dq*dq
+	if y1 > y2 {
+		return -delta
+	}
+	return delta
+}
+
+// pixelAt 返回以 (0, 0) 为原点的 RGBA 图片中的像素
+func pixelAt(img *image.RGBA, x, y int) color.RGBA {
+	i := y*img.Stride + x*4
+	return color.RGBA{R: img.Pix[i], G: img.Pix[i+1], B: img.Pix[i+2], A: img.Pix[i+3]}
+}
+
+// blendAlpha 将半透明像素与白色背景混合。image.RGBA 存储的是预乘后的颜色
+func blendAlpha(c color.RGBA) (r, g, b float64) {
+	transparent := 255 - float64(c.A)
+	return float64(c.R) + transparent, float64(c.G) + transparent, float64(c.B) + transparent
+}
+
+// yiqBrightness 返回像素与白色混合后的亮度
+func yiqBrightness(c color.RGBA) float64 {
+	return rgbToY(blendAlpha(c))
+}
+
+// blendWhite 将亮度按 alpha 与白色混合
+func blendWhite(v, alpha float64) uint8 {
+	return uint8(math.Round(255 + (v-255)*alpha))
+}
+
+func rgbToY(r, g, b float64) float64 { return r*0.29889531 + g*0.58662247 + b*0.11448223 }
+func rgbToI(r, g, b float64) float64 { return r*0.59597799 - g*0.27417610 - b*0.32180189 }
+func rgbToQ(r, g, b float64) float64 { return r*0.21147017 - g*0.52261711 + b*0.31114694 }
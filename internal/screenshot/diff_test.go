@@ -0,0 +1,274 @@
+package screenshot
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/gotoailab/snapup/internal/models"
+)
+
+// solidImage 创建纯色图片
+func solidImage(w, h int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// edgeImage 创建左侧黑色、右侧白色的图片，edge 不为 nil 时把分界列设为该颜色
+func edgeImage(w, h, split int, edge *color.RGBA) *image.RGBA {
+	img := solidImage(w, h, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	for y := 0; y < h; y++ {
+		for x := 0; x < split; x++ {
+			img.SetRGBA(x, y, color.RGBA{A: 255})
+		}
+		if edge != nil {
+			img.SetRGBA(split, y, *edge)
+		}
+	}
+	return img
+}
+
+func TestColorDelta(t *testing.T) {
+	black := color.RGBA{A: 255}
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	// 黑白之间只有亮度差异
+	blackWhite := 0.5053 * 255 * 255
+	tests := []struct {
+		name string
+		a, b color.RGBA
+		want float64
+	}{
+		{"相同", white, white, 0},
+		{"变亮为正", black, white, blackWhite},
+		{"变暗为负", white, black, -blackWhite},
+		// 透明像素与白色混合后与白色相同
+		{"透明等同于白色", color.RGBA{}, white, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := solidImage(1, 1, tt.a), solidImage(1, 1, tt.b)
+			got := colorDelta(a, b, 0, 0, 0, 0, false)
+			if math.Abs(got-tt.want) > 1 {
+				t.Fatalf("colorDelta = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// 任意两种颜色的差异都不超过 maxYIQDelta
+	for _, pair := range [][2]color.RGBA{{black, white}, {{R: 255, A: 255}, {B: 255, A: 255}}, {{G: 255, A: 255}, {R: 255, B: 255, A: 255}}} {
+		a, b := solidImage(1, 1, pair[0]), solidImage(1, 1, pair[1])
+		if got := math.Abs(colorDelta(a, b, 0, 0, 0, 0, false)); got > maxYIQDelta {
+			t.Fatalf("colorDelta(%v, %v) = %v 超过 maxYIQDelta", pair[0], pair[1], got)
+		}
+	}
+
+	// 只比较亮度时，灰度差异等于亮度差
+	a, b := solidImage(1, 1, color.RGBA{R: 100, G: 100, B: 100, A: 255}), solidImage(1, 1, color.RGBA{R: 90, G: 90, B: 90, A: 255})
+	if got := math.Abs(colorDelta(a, b, 0, 0, 0, 0, true)); math.Abs(got-10) > 1e-3 {
+		t.Fatalf("亮度差 = %v, want 10", got)
+	}
+}
+
+func TestCompareImages(t *testing.T) {
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	gray := color.RGBA{R: 128, G: 128, B: 128, A: 255}
+	red := color.RGBA{R: 255, A: 255}
+
+	withPixel := func(img *image.RGBA, x, y int, c color.RGBA) *image.RGBA {
+		img.SetRGBA(x, y, c)
+		return img
+	}
+
+	tests := []struct {
+		name     string
+		a, b     *image.RGBA
+		opts     DiffOptions
+		diff     int
+		aa       int
+		total    int
+		mismatch float64
+	}{
+		{
+			name:  "相同",
+			a:     solidImage(4, 4, white),
+			b:     solidImage(4, 4, white),
+			opts:  DiffOptions{Threshold: 0.1},
+			total: 16,
+		},
+		{
+			name:     "完全不同",
+			a:        solidImage(4, 4, white),
+			b:        solidImage(4, 4, color.RGBA{A: 255}),
+			opts:     DiffOptions{Threshold: 0.1},
+			diff:     16,
+			total:    16,
+			mismatch: 100,
+		},
+		{
+			name:  "容差内的细微差异",
+			a:     solidImage(4, 4, color.RGBA{R: 100, G: 100, B: 100, A: 255}),
+			b:     solidImage(4, 4, color.RGBA{R: 104, G: 104, B: 104, A: 255}),
+			opts:  DiffOptions{Threshold: 0.1},
+			total: 16,
+		},
+		{
+			name:     "容差为 0 时细微差异也计入",
+			a:        solidImage(4, 4, color.RGBA{R: 100, G: 100, B: 100, A: 255}),
+			b:        solidImage(4, 4, color.RGBA{R: 104, G: 104, B: 104, A: 255}),
+			opts:     DiffOptions{Threshold: 0},
+			diff:     16,
+			total:    16,
+			mismatch: 100,
+		},
+		{
+			name:     "孤立的像素不是抗锯齿",
+			a:        solidImage(5, 5, white),
+			b:        withPixel(solidImage(5, 5, white), 2, 2, red),
+			opts:     DiffOptions{Threshold: 0.1},
+			diff:     1,
+			total:    25,
+			mismatch: 4,
+		},
+		{
+			name:  "边缘的过渡色视为抗锯齿",
+			a:     edgeImage(10, 5, 5, nil),
+			b:     edgeImage(10, 5, 5, &gray),
+			opts:  DiffOptions{Threshold: 0.1},
+			aa:    5,
+			total: 50,
+		},
+		{
+			name:     "IncludeAA 时抗锯齿计入不同像素",
+			a:        edgeImage(10, 5, 5, nil),
+			b:        edgeImage(10, 5, 5, &gray),
+			opts:     DiffOptions{Threshold: 0.1, IncludeAA: true},
+			diff:     5,
+			total:    50,
+			mismatch: 10,
+		},
+		{
+			name:     "尺寸不同时多出的区域计为不同",
+			a:        solidImage(4, 4, white),
+			b:        solidImage(6, 5, white),
+			opts:     DiffOptions{Threshold: 0.1},
+			diff:     14,
+			total:    30,
+			mismatch: 14 * 100.0 / 30,
+		},
+		{
+			name:     "两张图片各有多出的区域",
+			a:        solidImage(6, 2, white),
+			b:        solidImage(2, 6, white),
+			opts:     DiffOptions{Threshold: 0.1},
+			diff:     36 - 4,
+			total:    36,
+			mismatch: 32 * 100.0 / 36,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := CompareImages(tt.a, tt.b, tt.opts)
+			if r.DiffPixels != tt.diff || r.AAPixels != tt.aa || r.TotalPixels != tt.total {
+				t.Fatalf("diff = %d, aa = %d, total = %d, want %d, %d, %d", r.DiffPixels, r.AAPixels, r.TotalPixels, tt.diff, tt.aa, tt.total)
+			}
+			if math.Abs(r.Mismatch-tt.mismatch) > 1e-9 {
+				t.Fatalf("mismatch = %v, want %v", r.Mismatch, tt.mismatch)
+			}
+			if b := r.Image.Bounds(); b.Dx()*b.Dy() != tt.total {
+				t.Fatalf("差异图尺寸 %v 与比较的像素数不符", b)
+			}
+		})
+	}
+}
+
+func TestCompareImagesMarks(t *testing.T) {
+	gray := color.RGBA{R: 128, G: 128, B: 128, A: 255}
+	r := CompareImages(edgeImage(10, 5, 5, nil), edgeImage(11, 5, 5, &gray), DiffOptions{Threshold: 0.1})
+	if got := r.Image.RGBAAt(5, 2); got != diffAAColor {
+		t.Fatalf("抗锯齿像素 = %v, want %v", got, diffAAColor)
+	}
+	if got := r.Image.RGBAAt(10, 2); got != diffExtraColor {
+		t.Fatalf("多出的像素 = %v, want %v", got, diffExtraColor)
+	}
+	// 相同的像素淡化为接近白色的灰度
+	if got := r.Image.RGBAAt(0, 0); got.R != got.G || got.G != got.B || got.R < 200 {
+		t.Fatalf("相同的像素 = %v", got)
+	}
+
+	r = CompareImages(solidImage(2, 2, color.RGBA{A: 255}), solidImage(2, 2, color.RGBA{R: 255, G: 255, B: 255, A: 255}), DiffOptions{})
+	if got := r.Image.RGBAAt(1, 1); got != diffColor {
+		t.Fatalf("不同的像素 = %v, want %v", got, diffColor)
+	}
+}
+
+func TestCheckImageName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"screenshot_desktop_1.png", true},
+		{"shot.PNG", true},
+		{"shot.jpg", true},
+		{"shot.jpeg", true},
+		{"shot.webp", false},
+		{"shot", false},
+		{"", false},
+		{".baselines.json", false},
+		{".hidden.png", false},
+		{"../secret.png", false},
+		{"dir/shot.png", false},
+		{`dir\shot.png`, false},
+		{"/etc/shot.png", false},
+		{"..", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkImageName(tt.name)
+			if valid := err == nil; valid != tt.valid {
+				t.Fatalf("checkImageName(%q) = %v, want valid = %v", tt.name, err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestNormalizeDiff(t *testing.T) {
+	threshold := func(v float64) *float64 { return &v }
+	file := models.DiffSource{Filename: "a.png"}
+	page := models.DiffSource{URL: "https://example.com"}
+
+	tests := []struct {
+		name  string
+		req   models.DiffRequest
+		valid bool
+	}{
+		{"两个文件", models.DiffRequest{Before: file, After: file}, true},
+		{"URL 和文件", models.DiffRequest{Before: page, After: file}, true},
+		{"缺少来源", models.DiffRequest{Before: file}, false},
+		{"同时指定 URL 和文件", models.DiffRequest{Before: models.DiffSource{URL: "https://example.com", Filename: "a.png"}, After: file}, false},
+		{"文件名无效", models.DiffRequest{Before: models.DiffSource{Filename: "../a.png"}, After: file}, false},
+		{"容差为 0", models.DiffRequest{Before: file, After: file, Threshold: threshold(0)}, true},
+		{"容差超过 1", models.DiffRequest{Before: file, After: file, Threshold: threshold(1.5)}, false},
+		{"允许的百分比为负", models.DiffRequest{Before: file, After: file, MaxMismatch: -1}, false},
+		{"允许的百分比超过 100", models.DiffRequest{Before: file, After: file, MaxMismatch: 101}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := normalizeDiff(&tt.req)
+			if valid := err == nil; valid != tt.valid {
+				t.Fatalf("normalizeDiff = %v, want valid = %v", err, tt.valid)
+			}
+			if err != nil && ErrorCode(err) != models.CodeInvalidRequest {
+				t.Fatalf("code = %q, want %q", ErrorCode(err), models.CodeInvalidRequest)
+			}
+			if err == nil && tt.req.Threshold == nil {
+				t.Fatalf("未指定容差时应填充默认值")
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package screenshot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	"github.com/gotoailab/snapup/internal/models"
+)
+
+// WebPEncoder WebP 编码器接口（标准库不支持 WebP，golang.org/x/image/webp 也只提供解码）
+type WebPEncoder interface {
+	EncodeWebP(ctx context.Context, pngData []byte, quality int) ([]byte, error)
+}
+
+// encodeImage 按指定格式和质量编码图片
+func encodeImage(ctx context.Context, img image.Image, format models.ImageFormat, quality int, webp WebPEncoder) ([]byte, error) {
+	var buf bytes.Buffer
+
+	switch format {
+	case models.FormatJPEG:
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, fmt.Errorf("编码 JPEG 失败: %w", err)
+		}
+		return buf.Bytes(), nil
+
+	case models.FormatWebP:
+		if webp == nil {
+			return nil, fmt.Errorf("未配置 WebP 编码器")
+		}
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("编码图片失败: %w", err)
+		}
+		return webp.EncodeWebP(ctx, buf.Bytes(), quality)
+
+	default:
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("编码 PNG 失败: %w", err)
+		}
+		return buf.Bytes(), nil
+	}
+}
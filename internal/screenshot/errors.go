@@ -0,0 +1,176 @@
+package screenshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/gotoailab/snapup/internal/models"
+	"github.com/gotoailab/snapup/internal/urlpolicy"
+)
+
+// Phase 截图流程的阶段
+type Phase string
+
+const (
+	PhaseBrowserStart Phase = "browser_start" // 获取浏览器并打开标签页
+	PhaseNavigation   Phase = "navigation"    // 设备模拟和页面导航
+	PhaseWait         Phase = "wait"          // 等待页面就绪
+	PhaseCapture      Phase = "capture"       // 截图
+	PhaseProcessing   Phase = "processing"    // 套用样式和编码
+	PhaseSave         Phase = "save"          // 保存文件
+)
+
+// phaseNames 阶段的中文名称
+var phaseNames = map[Phase]string{
+	PhaseBrowserStart: "启动浏览器",
+	PhaseNavigation:   "页面导航",
+	PhaseWait:         "等待页面就绪",
+	PhaseCapture:      "截图",
+	PhaseProcessing:   "处理图片",
+	PhaseSave:         "保存文件",
+}
+
+// PhaseError 某个阶段失败或超时
+type PhaseError struct {
+	Phase   Phase
+	Code    models.ErrorCode
+	Timeout bool // 是否因超时失败
+	Err     error
+}
+
+func (e *PhaseError) Error() string {
+	if e.Timeout {
+		return fmt.Sprintf("%s超时: %v", phaseNames[e.Phase], e.Err)
+	}
+	return fmt.Sprintf("%s失败: %v", phaseNames[e.Phase], e.Err)
+}
+
+func (e *PhaseError) Unwrap() error {
+	return e.Err
+}
+
+// errRequestTimeout 整个请求超过了超时时间
+var errRequestTimeout = errors.New("已超过请求的超时时间")
+
+// newPhaseError 包装阶段错误并归类错误码。ctx 为整个请求的上下文，
+// 其截止时间已过时无论底层错误是什么都视为该阶段超时。
+func newPhaseError(ctx context.Context, phase Phase, err error) *PhaseError {
+	pe := &PhaseError{Phase: phase, Err: err}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		pe.Timeout = true
+		pe.Err = errRequestTimeout
+	} else {
+		pe.Timeout = errors.Is(err, context.DeadlineExceeded)
+	}
+	pe.Code = classifyError(phase, pe.Timeout, err)
+	return pe
+}
+
+// classifyError 根据阶段和底层错误确定错误码
+func classifyError(phase Phase, timeout bool, err error) models.ErrorCode {
+	var navErr *navigationError
+	var statusErr *httpStatusError
+	var violation *urlpolicy.Violation
+	switch {
+	case phase == PhaseBrowserStart:
+		return models.CodeBrowserUnavailable
+	case phase == PhaseSave:
+		return models.CodeStorageFailed
+	case errors.As(err, &violation):
+		return models.CodeURLBlocked
+	case errors.As(err, &statusErr):
+		return models.CodeHTTPErrorStatus
+	case errors.As(err, &navErr) && navErr.dnsFailure():
+		return models.CodeDNSFailure
+	case errors.As(err, &navErr):
+		return models.CodeNavigationFailed
+	case timeout && (phase == PhaseNavigation || phase == PhaseWait):
+		return models.CodeNavigationTimeout
+	default:
+		return models.CodeRenderFailed
+	}
+}
+
+// ErrorCode 返回错误对应的错误码，无法归类的错误视为渲染失败
+func ErrorCode(err error) models.ErrorCode {
+	var pe *PhaseError
+	if errors.As(err, &pe) {
+		return pe.Code
+	}
+	var re *requestError
+	if errors.As(err, &re) {
+		return re.code
+	}
+	var violation *urlpolicy.Violation
+	if errors.As(err, &violation) {
+		return models.CodeURLBlocked
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return models.CodeDNSFailure
+	}
+	return models.CodeRenderFailed
+}
+
+// requestError 请求被拒绝，如参数无效或超出并发限制
+type requestError struct {
+	code models.ErrorCode
+	msg  string
+}
+
+func (e *requestError) Error() string {
+	return e.msg
+}
+
+// invalidRequest 创建请求参数无效的错误
+func invalidRequest(format string, args ...interface{}) error {
+	return &requestError{code: models.CodeInvalidRequest, msg: fmt.Sprintf(format, args...)}
+}
+
+// invalidURL 创建 URL 无效的错误
+func invalidURL(format string, args ...interface{}) error {
+	return &requestError{code: models.CodeInvalidURL, msg: fmt.Sprintf(format, args...)}
+}
+
+// concurrencyLimited 创建超出并发截图上限的错误
+func concurrencyLimited(format string, args ...interface{}) error {
+	return &requestError{code: models.CodeConcurrencyLimit, msg: fmt.Sprintf(format, args...)}
+}
+
+// notFound 创建请求的资源不存在的错误
+func notFound(format string, args ...interface{}) error {
+	return &requestError{code: models.CodeNotFound, msg: fmt.Sprintf(format, args...)}
+}
+
+// navigationError 浏览器报告的导航错误，如 net::ERR_NAME_NOT_RESOLVED
+type navigationError struct {
+	text string
+}
+
+func (e *navigationError) Error() string {
+	return fmt.Sprintf("页面加载失败: %s", e.text)
+}
+
+// dnsFailure 判断是否为域名解析失败
+func (e *navigationError) dnsFailure() bool {
+	return strings.Contains(e.text, "ERR_NAME_NOT_RESOLVED") ||
+		strings.Contains(e.text, "ERR_NAME_RESOLUTION_FAILED")
+}
+
+// blockedByClient 判断是否因请求被拦截而失败
+func (e *navigationError) blockedByClient() bool {
+	return strings.Contains(e.text, "ERR_BLOCKED_BY_CLIENT")
+}
+
+// httpStatusError 页面返回了错误的 HTTP 状态码
+type httpStatusError struct {
+	status int64
+	text   string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("页面返回 HTTP %d %s", e.status, e.text)
+}
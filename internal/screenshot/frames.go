@@ -0,0 +1,398 @@
+package screenshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gotoailab/snapup/internal/models"
+)
+
+// FrameInsets 屏幕四周边框宽度，以屏幕宽度为单位的比例
+type FrameInsets struct {
+	Top    float64
+	Right  float64
+	Bottom float64
+	Left   float64
+}
+
+// FrameCutout 屏幕或边框上的开孔（刘海、灵动岛、摄像头）
+type FrameCutout struct {
+	Width   float64    // 宽度（屏幕宽度的比例）
+	Height  float64    // 高度（屏幕宽度的比例）
+	OffsetY float64    // 相对屏幕顶部的偏移（屏幕宽度的比例），负值表示位于边框内
+	Radius  float64    // 圆角（屏幕宽度的比例），0 表示胶囊或圆形
+	Color   color.RGBA // 填充颜色
+	Lens    bool       // 是否绘制镜头反光
+}
+
+// DeviceFrame 设备外框定义
+//
+// 矢量外框以屏幕宽度为单位描述边框、圆角和开孔，能适配任意尺寸（包括全页）
+// 的截图；位图外框使用一张屏幕区域透明的外壳图片，截图会缩放后放入 Screen 区域。
+type DeviceFrame struct {
+	Name string
+
+	// 矢量外框
+	Bezel        FrameInsets   // 屏幕四周的边框
+	BodyRadius   float64       // 外壳圆角
+	ScreenRadius float64       // 屏幕圆角
+	BodyColor    color.RGBA    // 外壳（边框）颜色
+	EdgeColor    color.RGBA    // 外壳描边颜色
+	Chin         float64       // 底部下巴高度（显示器），使用 ChinColor 绘制
+	ChinColor    color.RGBA    // 下巴颜色
+	Cutouts      []FrameCutout // 刘海、灵动岛、摄像头
+	SideButtons  bool          // 是否绘制侧边按键（手机）
+	Base         bool          // 是否绘制键盘底座（笔记本）
+	Stand        bool          // 是否绘制支架（显示器）
+
+	// 位图外框
+	BezelImage image.Image     // 外壳图片，屏幕区域需透明
+	Screen     image.Rectangle // 外壳图片中的屏幕区域
+}
+
+var (
+	frameBlack  = color.RGBA{R: 12, G: 12, B: 14, A: 255}
+	frameSilver = color.RGBA{R: 206, G: 208, B: 213, A: 255}
+	frameMetal  = color.RGBA{R: 88, G: 90, B: 96, A: 255}
+	frameLens   = color.RGBA{R: 28, G: 30, B: 46, A: 255}
+)
+
+// builtinFrames 内置的矢量外框
+var builtinFrames = map[string]DeviceFrame{
+	"phone": {
+		Name:         "phone",
+		Bezel:        FrameInsets{Top: 0.045, Right: 0.045, Bottom: 0.045, Left: 0.045},
+		BodyRadius:   0.17,
+		ScreenRadius: 0.125,
+		BodyColor:    frameBlack,
+		EdgeColor:    frameMetal,
+		Cutouts: []FrameCutout{
+			// 灵动岛
+			{Width: 0.30, Height: 0.085, OffsetY: 0.03, Color: frameBlack, Lens: true},
+		},
+		SideButtons: true,
+	},
+	"tablet": {
+		Name:         "tablet",
+		Bezel:        FrameInsets{Top: 0.055, Right: 0.055, Bottom: 0.055, Left: 0.055},
+		BodyRadius:   0.065,
+		ScreenRadius: 0.025,
+		BodyColor:    frameBlack,
+		EdgeColor:    frameMetal,
+		Cutouts: []FrameCutout{
+			// 前置摄像头
+			{Width: 0.014, Height: 0.014, OffsetY: -0.0345, Color: frameLens, Lens: true},
+		},
+	},
+	"laptop": {
+		Name:         "laptop",
+		Bezel:        FrameInsets{Top: 0.035, Right: 0.022, Bottom: 0.04, Left: 0.022},
+		BodyRadius:   0.03,
+		ScreenRadius: 0.008,
+		BodyColor:    frameBlack,
+		EdgeColor:    frameMetal,
+		Cutouts: []FrameCutout{
+			// 刘海和摄像头
+			{Width: 0.12, Height: 0.022, OffsetY: -0.004, Radius: 0.008, Color: frameBlack},
+			{Width: 0.009, Height: 0.009, OffsetY: 0.004, Color: frameLens, Lens: true},
+		},
+		Base: true,
+	},
+	"monitor": {
+		Name:         "monitor",
+		Bezel:        FrameInsets{Top: 0.02, Right: 0.02, Bottom: 0.02, Left: 0.02},
+		BodyRadius:   0.02,
+		ScreenRadius: 0.004,
+		BodyColor:    frameBlack,
+		EdgeColor:    frameSilver,
+		Chin:         0.09,
+		ChinColor:    frameSilver,
+		Cutouts: []FrameCutout{
+			{Width: 0.007, Height: 0.007, OffsetY: -0.01, Color: frameLens, Lens: true},
+		},
+		Stand: true,
+	},
+}
+
+// RegisterFrame 注册（或覆盖）一个外框
+func (p *ImageProcessor) RegisterFrame(name string, frame DeviceFrame) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	frame.Name = name
+	p.frames[name] = frame
+}
+
+// frameFor 返回设备对应的外框
+//
+// 依次查找设备配置中指定的外框和以设备类型命名的外框，都找不到时按设备尺寸推断内置外框。
+// 请求覆盖了视口尺寸或移动模式时（InferFrame），预设的外框和设备类型都不再适用，直接按尺寸推断。
+func (p *ImageProcessor) frameFor(device models.DeviceConfig) DeviceFrame {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if device.Frame != "" {
+		if frame, ok := p.frames[device.Frame]; ok {
+			return frame
+		}
+	}
+	if device.Type != "" && !device.InferFrame {
+		if frame, ok := p.frames[string(device.Type)]; ok {
+			return frame
+		}
+	}
+	return p.frames[guessFrameName(device)]
+}
+
+// guessFrameName 根据设备尺寸推断合适的内置外框
+func guessFrameName(device models.DeviceConfig) string {
+	switch {
+	case device.Mobile && device.Width < 600:
+		return "phone"
+	case device.Mobile:
+		return "tablet"
+	case device.Width < 1600:
+		return "laptop"
+	default:
+		return "monitor"
+	}
+}
+
+// frameImageSpec 位图外框描述文件
+type frameImageSpec struct {
+	Image  string `json:"image"` // 外壳图片路径（相对描述文件所在目录）
+	Screen struct {
+		X      int `json:"x"`
+		Y      int `json:"y"`
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	} `json:"screen"`
+}
+
+// LoadFrames 从目录加载位图外框，每个 <name>.json 描述文件注册为名为 <name> 的外框
+func (p *ImageProcessor) LoadFrames(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		frame, err := loadImageFrame(file)
+		if err != nil {
+			return fmt.Errorf("加载外框 %s 失败: %w", file, err)
+		}
+		name := strings.TrimSuffix(filepath.Base(file), ".json")
+		p.RegisterFrame(name, frame)
+	}
+	return nil
+}
+
+// loadImageFrame 读取位图外框描述文件和外壳图片
+func loadImageFrame(file string) (DeviceFrame, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return DeviceFrame{}, err
+	}
+
+	var spec frameImageSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return DeviceFrame{}, fmt.Errorf("解析描述文件失败: %w", err)
+	}
+
+	f, err := os.Open(filepath.Join(filepath.Dir(file), spec.Image))
+	if err != nil {
+		return DeviceFrame{}, err
+	}
+	defer f.Close()
+
+	bezel, _, err := image.Decode(f)
+	if err != nil {
+		return DeviceFrame{}, fmt.Errorf("解码外壳图片失败: %w", err)
+	}
+
+	screen := image.Rect(spec.Screen.X, spec.Screen.Y, spec.Screen.X+spec.Screen.Width, spec.Screen.Y+spec.Screen.Height)
+	if screen.Empty() || !screen.In(bezel.Bounds()) {
+		return DeviceFrame{}, fmt.Errorf("屏幕区域 %v 超出外壳图片范围", screen)
+	}
+
+	return DeviceFrame{BezelImage: bezel, Screen: screen}, nil
+}
+
+// renderDeviceFrame 将截图合成到设备外框中，返回背景透明的设备图片
+func renderDeviceFrame(img image.Image, frame DeviceFrame) *image.RGBA {
+	if frame.BezelImage != nil {
+		return renderImageFrame(img, frame)
+	}
+	return renderVectorFrame(img, frame)
+}
+
+// renderImageFrame 将截图按屏幕宽度缩放（超出部分从底部裁掉）后放入位图外框
+func renderImageFrame(img image.Image, frame DeviceFrame) *image.RGBA {
+	bb := frame.BezelImage.Bounds()
+	result := image.NewRGBA(image.Rect(0, 0, bb.Dx(), bb.Dy()))
+
+	screen := frame.Screen.Sub(bb.Min)
+	b := img.Bounds()
+	scaledH := int(math.Round(float64(b.Dy()) * float64(screen.Dx()) / float64(b.Dx())))
+	var content image.Image
+	if scaledH >= screen.Dy() {
+		content = resizeImage(img, screen.Dx(), scaledH)
+	} else {
+		content = coverImage(img, screen.Dx(), screen.Dy())
+	}
+
+	draw.Draw(result, screen, content, image.Point{}, draw.Src)
+	draw.Draw(result, result.Bounds(), frame.BezelImage, bb.Min, draw.Over)
+	return result
+}
+
+// renderVectorFrame 按矢量描述绘制设备外框并放入截图
+func renderVectorFrame(img image.Image, frame DeviceFrame) *image.RGBA {
+	b := img.Bounds()
+	u := float64(b.Dx())
+	px := func(v float64) int { return int(math.Round(v * u)) }
+
+	top, right, bottom, left := px(frame.Bezel.Top), px(frame.Bezel.Right), px(frame.Bezel.Bottom), px(frame.Bezel.Left)
+	chin := px(frame.Chin)
+	bodyW := left + b.Dx() + right
+	bodyH := top + b.Dy() + bottom + chin
+
+	// 画布需要额外容纳侧边按键、键盘底座和支架
+	marginX, baseW, baseH, standH := 0, 0, 0, 0
+	if frame.SideButtons {
+		marginX = max(px(0.012), 2)
+	}
+	if frame.Base {
+		baseW = int(float64(bodyW) * 1.16)
+		baseH = max(px(0.028), 4)
+		marginX = max(marginX, (baseW-bodyW+1)/2)
+	}
+	if frame.Stand {
+		standH = px(0.16)
+	}
+
+	width := bodyW + marginX*2
+	height := bodyH + baseH + standH
+	result := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	body := image.Rect(marginX, 0, marginX+bodyW, bodyH)
+	bodyRadius := frame.BodyRadius * u
+
+	// 侧边按键画在外壳之下
+	if frame.SideButtons {
+		drawSideButtons(result, body, u, frame.EdgeColor)
+	}
+
+	// 支架画在外壳之下
+	if frame.Stand {
+		drawMonitorStand(result, body, u, standH, frame.ChinColor)
+	}
+
+	// 外壳和下巴
+	if chin > 0 {
+		fillRoundedRect(result, body, bodyRadius, frame.ChinColor)
+		panel := image.Rect(body.Min.X, body.Min.Y, body.Max.X, body.Max.Y-chin)
+		fillRoundedRect(result, panel, bodyRadius, frame.BodyColor)
+		squared := image.Rect(panel.Min.X, panel.Max.Y-int(math.Ceil(bodyRadius)), panel.Max.X, panel.Max.Y)
+		draw.Draw(result, squared.Intersect(panel), &image.Uniform{frame.BodyColor}, image.Point{}, draw.Src)
+	} else {
+		fillRoundedRect(result, body, bodyRadius, frame.BodyColor)
+	}
+	strokeRoundedRect(result, body, bodyRadius, math.Max(1.5, u*0.004), frame.EdgeColor)
+
+	// 屏幕
+	screen := image.Rect(body.Min.X+left, top, body.Min.X+left+b.Dx(), top+b.Dy())
+	drawRoundedImage(result, screen, img, frame.ScreenRadius*u)
+
+	// 开孔
+	for _, cutout := range frame.Cutouts {
+		drawCutout(result, screen, u, cutout)
+	}
+
+	// 键盘底座
+	if frame.Base {
+		drawLaptopBase(result, body, u, baseW, baseH)
+	}
+
+	return result
+}
+
+// drawCutout 在屏幕顶部居中位置绘制开孔
+func drawCutout(dst *image.RGBA, screen image.Rectangle, u float64, cutout FrameCutout) {
+	w := math.Max(cutout.Width*u, 2)
+	h := math.Max(cutout.Height*u, 2)
+	cx := float64(screen.Min.X+screen.Max.X) / 2
+	y := float64(screen.Min.Y) + cutout.OffsetY*u
+
+	rect := image.Rect(int(math.Round(cx-w/2)), int(math.Round(y)), int(math.Round(cx+w/2)), int(math.Round(y+h)))
+	radius := cutout.Radius * u
+	if cutout.Radius == 0 {
+		radius = math.Min(w, h) / 2
+	}
+	fillRoundedRect(dst, rect, radius, cutout.Color)
+
+	// 镜头：靠右侧的小圆点和高光
+	if cutout.Lens {
+		d := math.Min(w, h) * 0.5
+		lx := float64(rect.Max.X) - math.Min(w, h)/2 - d/2
+		ly := float64(rect.Min.Y) + (h-d)/2
+		lens := image.Rect(int(lx), int(ly), int(lx+d), int(ly+d))
+		fillRoundedRect(dst, lens, d/2, color.RGBA{R: 36, G: 44, B: 72, A: 255})
+		glint := math.Max(d*0.3, 1)
+		fillRoundedRect(dst, image.Rect(int(lx+d*0.2), int(ly+d*0.2), int(lx+d*0.2+glint), int(ly+d*0.2+glint)), glint/2, color.NRGBA{R: 120, G: 140, B: 200, A: 160})
+	}
+}
+
+// drawSideButtons 绘制手机两侧的按键
+func drawSideButtons(dst *image.RGBA, body image.Rectangle, u float64, c color.RGBA) {
+	w := max(int(u*0.012), 2)
+	r := float64(w) / 2
+	y := func(v float64) int { return body.Min.Y + int(v*u) }
+
+	// 左侧：静音键和音量键
+	fillRoundedRect(dst, image.Rect(body.Min.X-w, y(0.28), body.Min.X+w, y(0.36)), r, c)
+	fillRoundedRect(dst, image.Rect(body.Min.X-w, y(0.46), body.Min.X+w, y(0.62)), r, c)
+	fillRoundedRect(dst, image.Rect(body.Min.X-w, y(0.68), body.Min.X+w, y(0.84)), r, c)
+	// 右侧：电源键
+	fillRoundedRect(dst, image.Rect(body.Max.X-w, y(0.52), body.Max.X+w, y(0.78)), r, c)
+}
+
+// drawLaptopBase 在屏幕下方绘制笔记本键盘底座
+func drawLaptopBase(dst *image.RGBA, body image.Rectangle, u float64, baseW, baseH int) {
+	cx := (body.Min.X + body.Max.X) / 2
+	base := image.Rect(cx-baseW/2, body.Max.Y, cx+baseW/2, body.Max.Y+baseH)
+
+	// 底座主体，底部圆角略大
+	fillRoundedRect(dst, base, float64(baseH)/2, frameSilver)
+	draw.Draw(dst, image.Rect(base.Min.X, base.Min.Y, base.Max.X, base.Min.Y+baseH/2), &image.Uniform{frameSilver}, image.Point{}, draw.Src)
+
+	// 底部阴影边
+	edge := max(baseH/4, 1)
+	fillRoundedRect(dst, image.Rect(base.Min.X+baseH, base.Max.Y-edge, base.Max.X-baseH, base.Max.Y), float64(edge)/2, color.RGBA{R: 150, G: 152, B: 158, A: 255})
+
+	// 开盖凹槽
+	lipW := int(u * 0.16)
+	lipH := max(baseH/3, 2)
+	fillRoundedRect(dst, image.Rect(cx-lipW/2, base.Min.Y, cx+lipW/2, base.Min.Y+lipH), float64(lipH)/2, color.RGBA{R: 170, G: 172, B: 178, A: 255})
+}
+
+// drawMonitorStand 在显示器下方绘制支架和底座
+func drawMonitorStand(dst *image.RGBA, body image.Rectangle, u float64, standH int, c color.RGBA) {
+	cx := (body.Min.X + body.Max.X) / 2
+	neckW := int(u * 0.18)
+	footH := max(int(u*0.012), 2)
+	footW := int(u * 0.3)
+
+	neck := image.Rect(cx-neckW/2, body.Max.Y-int(u*0.02), cx+neckW/2, body.Max.Y+standH-footH)
+	draw.Draw(dst, neck, &image.Uniform{color.RGBA{R: 188, G: 190, B: 196, A: 255}}, image.Point{}, draw.Src)
+
+	foot := image.Rect(cx-footW/2, body.Max.Y+standH-footH*2, cx+footW/2, body.Max.Y+standH)
+	fillRoundedRect(dst, foot, float64(footH), c)
+}
@@ -0,0 +1,183 @@
+package screenshot
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+
+	"github.com/gotoailab/snapup/internal/models"
+)
+
+// 玻璃效果默认参数
+const (
+	defaultGlassBlur    = 40
+	defaultGlassOpacity = 0.25
+)
+
+// maxBackgroundPixels 背景图片允许的最大像素数，解码前按图片头中的尺寸检查
+const maxBackgroundPixels = 25_000_000
+
+// GlassOptions 玻璃效果渲染参数
+type GlassOptions struct {
+	Blur       float64     // 背景模糊半径（像素）
+	Opacity    float64     // 玻璃卡片不透明度 (0-1)
+	Highlight  bool        // 是否绘制左上角高光渐变
+	Background image.Image // 背景图片，为 nil 时使用截图本身
+}
+
+// glassOptionsFromRequest 根据请求参数生成玻璃效果参数
+func (p *ImageProcessor) glassOptionsFromRequest(spec *models.GlassSpec) (GlassOptions, error) {
+	opts := GlassOptions{
+		Blur:      defaultGlassBlur,
+		Opacity:   defaultGlassOpacity,
+		Highlight: true,
+	}
+	if spec == nil {
+		return opts, nil
+	}
+
+	if spec.Blur != nil {
+		opts.Blur = float64(max(*spec.Blur, 0))
+	}
+	if spec.Opacity != nil {
+		opts.Opacity = math.Max(0, math.Min(*spec.Opacity, 1))
+	}
+	if spec.Highlight != nil {
+		opts.Highlight = *spec.Highlight
+	}
+	if spec.BackgroundImage != "" {
+		bg, err := decodeDataImage(spec.BackgroundImage)
+		if err != nil {
+			return opts, fmt.Errorf("解析背景图片失败: %w", err)
+		}
+		opts.Background = bg
+	}
+	return opts, nil
+}
+
+// decodeDataImage 解码 base64 或 data URI 形式的图片，解码前检查编码长度和图片尺寸
+func decodeDataImage(value string) (image.Image, error) {
+	if len(value) > models.MaxBackgroundImageSize {
+		return nil, fmt.Errorf("图片数据不能超过 %d 字节", models.MaxBackgroundImageSize)
+	}
+	if strings.HasPrefix(value, "data:") {
+		comma := strings.Index(value, ",")
+		if comma < 0 || !strings.Contains(value[:comma], ";base64") {
+			return nil, fmt.Errorf("仅支持 base64 编码的 data URI")
+		}
+		value = value[comma+1:]
+	}
+
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > maxBackgroundPixels {
+		return nil, fmt.Errorf("图片过大，不能超过 %d 像素", maxBackgroundPixels)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// renderGlassBackground 渲染铺满画布的模糊背景
+//
+// 先把背景缩小到 1/8 再模糊并放大回画布尺寸，效果等同于在原尺寸上使用
+// 更大的模糊半径，计算量却小得多。
+func renderGlassBackground(src image.Image, width, height int, blur float64) *image.RGBA {
+	const factor = 8
+
+	smallW := max(width/factor, 1)
+	smallH := max(height/factor, 1)
+
+	// 略微放大背景，避免模糊后边缘出现透明或发灰的过渡
+	zoomW := int(float64(smallW) * 1.1)
+	zoomH := int(float64(smallH) * 1.1)
+	small := coverImage(src, zoomW, zoomH)
+	cropped := image.NewRGBA(image.Rect(0, 0, smallW, smallH))
+	draw.Draw(cropped, cropped.Bounds(), small, image.Pt((zoomW-smallW)/2, (zoomH-smallH)/2), draw.Src)
+
+	blurred := blurImage(cropped, blur/2/factor)
+	return resizeImage(blurred, width, height)
+}
+
+// drawGlassHighlight 在卡片区域内绘制从左上角向中部渐隐的白色高光
+func drawGlassHighlight(dst draw.Image, rect image.Rectangle, r float64) {
+	w, h := rect.Dx(), rect.Dy()
+	gradient := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	// 沿对角线方向线性渐变，只覆盖卡片的左上半部分
+	length := float64(w + h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			t := float64(x+y) / (length * 0.5)
+			if t >= 1 {
+				break
+			}
+			a := uint8(70 * (1 - t))
+			i := y*gradient.Stride + x*4
+			gradient.Pix[i], gradient.Pix[i+1], gradient.Pix[i+2], gradient.Pix[i+3] = a, a, a, a
+		}
+	}
+
+	mask := roundedRectMask(w, h, r)
+	draw.DrawMask(dst, rect, gradient, image.Point{}, mask, image.Point{}, draw.Over)
+}
+
+// applyGlassStyle 应用玻璃拟态样式：模糊背景上的半透明圆角卡片包裹截图
+func (p *ImageProcessor) applyGlassStyle(img image.Image, bgColor string, spec *models.GlassSpec) (image.Image, error) {
+	opts, err := p.glassOptionsFromRequest(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	padding := 60
+	cardInset := 16
+	borderRadius := 12
+	cardRadius := float64(borderRadius + cardInset)
+
+	newWidth := bounds.Dx() + padding*2
+	newHeight := bounds.Dy() + padding*2
+
+	result := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+
+	// 填充背景色，再覆盖模糊放大的背景图
+	bg := p.parseColor(bgColor, color.RGBA{R: 240, G: 242, B: 245, A: 255})
+	draw.Draw(result, result.Bounds(), &image.Uniform{bg}, image.Point{}, draw.Src)
+
+	backgroundSrc := opts.Background
+	if backgroundSrc == nil {
+		backgroundSrc = img
+	}
+	draw.Draw(result, result.Bounds(), renderGlassBackground(backgroundSrc, newWidth, newHeight, opts.Blur), image.Point{}, draw.Over)
+
+	// 玻璃卡片
+	screenRect := image.Rect(padding, padding, padding+bounds.Dx(), padding+bounds.Dy())
+	cardRect := screenRect.Inset(-cardInset)
+
+	drawShadow(result, cardRect, cardRadius, ShadowOptions{
+		Blur:    30,
+		OffsetY: 10,
+		Color:   color.RGBA{A: 255},
+		Opacity: 0.15,
+	})
+	fillRoundedRect(result, cardRect, cardRadius, color.NRGBA{R: 255, G: 255, B: 255, A: uint8(opts.Opacity * 255)})
+	if opts.Highlight {
+		drawGlassHighlight(result, cardRect, cardRadius)
+	}
+	strokeRoundedRect(result, cardRect, cardRadius, 1.5, color.NRGBA{R: 255, G: 255, B: 255, A: 140})
+
+	// 绘制圆角裁剪后的原图
+	drawRoundedImage(result, screenRect, img, float64(borderRadius))
+
+	return result, nil
+}
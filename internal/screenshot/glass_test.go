@@ -0,0 +1,71 @@
+package screenshot
+
+import (
+	"testing"
+
+	"github.com/gotoailab/snapup/internal/models"
+)
+
+func TestGlassOptionsFromRequest(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	floatPtr := func(v float64) *float64 { return &v }
+	boolPtr := func(v bool) *bool { return &v }
+
+	tests := []struct {
+		name      string
+		spec      *models.GlassSpec
+		blur      float64
+		opacity   float64
+		highlight bool
+	}{
+		{"未指定参数", nil, defaultGlassBlur, defaultGlassOpacity, true},
+		{"空对象使用默认值", &models.GlassSpec{}, defaultGlassBlur, defaultGlassOpacity, true},
+		{"显式指定", &models.GlassSpec{Blur: intPtr(12), Opacity: floatPtr(0.6), Highlight: boolPtr(false)}, 12, 0.6, false},
+		{"0 表示不模糊、完全透明", &models.GlassSpec{Blur: intPtr(0), Opacity: floatPtr(0)}, 0, 0, true},
+		{"超出范围时截断", &models.GlassSpec{Blur: intPtr(-5), Opacity: floatPtr(1.5)}, 0, 1, true},
+	}
+	p := NewImageProcessor()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := p.glassOptionsFromRequest(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if opts.Blur != tt.blur || opts.Opacity != tt.opacity || opts.Highlight != tt.highlight {
+				t.Fatalf("opts = %+v, want blur %v opacity %v highlight %v", opts, tt.blur, tt.opacity, tt.highlight)
+			}
+		})
+	}
+}
+
+func TestValidateGlass(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	floatPtr := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name  string
+		glass *models.GlassSpec
+		valid bool
+	}{
+		{"未指定", &models.GlassSpec{}, true},
+		{"0", &models.GlassSpec{Blur: intPtr(0), Opacity: floatPtr(0)}, true},
+		{"上限", &models.GlassSpec{Blur: intPtr(200), Opacity: floatPtr(1)}, true},
+		{"模糊半径为负", &models.GlassSpec{Blur: intPtr(-1)}, false},
+		{"模糊半径过大", &models.GlassSpec{Blur: intPtr(201)}, false},
+		{"不透明度为负", &models.GlassSpec{Opacity: floatPtr(-0.1)}, false},
+		{"不透明度超过 1", &models.GlassSpec{Opacity: floatPtr(1.1)}, false},
+	}
+	s := &Service{devices: models.NewDeviceRegistry()}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := models.ScreenshotRequest{URL: "https://example.com", Style: models.StyleGlass, Glass: tt.glass}
+			err := s.validateRequest(&req)
+			if valid := err == nil; valid != tt.valid {
+				t.Fatalf("validateRequest = %v, want valid = %v", err, tt.valid)
+			}
+			if err != nil && ErrorCode(err) != models.CodeInvalidRequest {
+				t.Fatalf("code = %q, want %q", ErrorCode(err), models.CodeInvalidRequest)
+			}
+		})
+	}
+}
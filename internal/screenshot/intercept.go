@@ -0,0 +1,175 @@
+package screenshot
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gotoailab/snapup/internal/urlpolicy"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// disableSocketsJS 在每个页面（包括同源的空白 iframe）加载前禁用 WebSocket。
+// WebSocket 连接不经过请求拦截，无法按访问策略检查。使用 WebSocket 的页面会得到 SecurityError，而不是整个截图失败。
+const disableSocketsJS = `(() => {
+	Object.defineProperty(window, "WebSocket", {
+		value: function () { throw new DOMException("WebSocket is disabled", "SecurityError"); },
+		writable: false,
+		configurable: false
+	});
+})();`
+
+// disableWorkersJS 禁用 Worker、SharedWorker 和 Service Worker 注册。Worker 中的 WebSocket 不受页面脚本影响，
+// 也不会触发标签页上的事件，访问策略配置了 DisableWorkers 时用于截图不可信的页面。
+const disableWorkersJS = `(() => {
+	const disabled = (name) => function () { throw new DOMException(name + " is disabled", "SecurityError"); };
+	for (const name of ["Worker", "SharedWorker"]) {
+		Object.defineProperty(window, name, { value: disabled(name), writable: false, configurable: false });
+	}
+	if (typeof ServiceWorkerContainer !== "undefined") {
+		Object.defineProperty(ServiceWorkerContainer.prototype, "register", {
+			value: () => Promise.reject(new DOMException("ServiceWorker is disabled", "SecurityError")),
+			writable: false,
+			configurable: false
+		});
+	}
+})();`
+
+// requestGuard 拦截标签页发出的所有请求（包括每一次重定向和子资源），
+// 按 URL 访问策略放行或拒绝，并检查每个响应实际连接的地址
+type requestGuard struct {
+	checker *urlpolicy.Checker
+	policy  *urlpolicy.Policy
+	abort   context.CancelFunc // 关闭标签页，发现违反策略的连接时立即停止加载
+
+	mu sync.Mutex
+	// documentViolation 最近一次被拒绝的文档请求，用于解释导航失败的原因
+	documentViolation error
+	// blockedErr 第一个绕过请求拦截的违规连接（DNS 重绑定或 WebSocket），出现后截图失败
+	blockedErr error
+}
+
+// guardRequests 在标签页上开启请求拦截，需在导航之前调用。policy 为 nil 时不拦截。
+// 请求拦截覆盖不到的连接在事件中检查，违反策略时调用 abort 关闭标签页：
+// 响应实际连接的地址（DNS 重绑定）和绕过脚本创建的 WebSocket 连接。
+// Service Worker 发出的请求同样不经过标签页的请求拦截，因此让页面请求绕过已注册的 Service Worker。
+func guardRequests(ctx context.Context, abort context.CancelFunc, policy *urlpolicy.Policy) (*requestGuard, chromedp.Action) {
+	if policy == nil {
+		return nil, chromedp.ActionFunc(func(context.Context) error { return nil })
+	}
+
+	g := &requestGuard{checker: policy.NewChecker(), policy: policy, abort: abort}
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *fetch.EventRequestPaused:
+			// 事件回调中不能阻塞，检查（可能需要解析域名）放到单独的 goroutine 中
+			go g.handle(ctx, ev)
+		case *network.EventResponseReceived:
+			if err := g.checkResponse(ev.Response); err != nil {
+				g.block(err)
+			}
+		case *network.EventWebSocketCreated:
+			g.block(&urlpolicy.Violation{URL: ev.URL, Reason: "不允许 WebSocket 连接"})
+		}
+	})
+
+	return g, chromedp.Tasks{
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			script := disableSocketsJS
+			if policy.DisableWorkers() {
+				script += "\n" + disableWorkersJS
+			}
+			_, err := page.AddScriptToEvaluateOnNewDocument(script).Do(ctx)
+			return err
+		}),
+		network.SetBypassServiceWorker(true),
+		fetch.Enable().WithPatterns([]*fetch.RequestPattern{{URLPattern: "*"}}),
+	}
+}
+
+// handle 检查被暂停的请求并放行或拒绝
+func (g *requestGuard) handle(ctx context.Context, ev *fetch.EventRequestPaused) {
+	c := chromedp.FromContext(ctx)
+	if c == nil || c.Target == nil {
+		return
+	}
+	execCtx := cdp.WithExecutor(ctx, c.Target)
+
+	var err error
+	if checkErr := g.checker.Check(ctx, ev.Request.URL); checkErr != nil {
+		if ev.ResourceType == network.ResourceTypeDocument {
+			g.mu.Lock()
+			g.documentViolation = checkErr
+			g.mu.Unlock()
+		}
+		err = fetch.FailRequest(ev.RequestID, network.ErrorReasonBlockedByClient).Do(execCtx)
+	} else {
+		err = fetch.ContinueRequest(ev.RequestID).Do(execCtx)
+	}
+	if err != nil && ctx.Err() == nil {
+		log.Printf("处理拦截的请求失败: %v", err)
+	}
+}
+
+// checkResponse 检查 HTTP 响应实际连接的地址。浏览器连接时可能重新解析域名，
+// 得到的地址与拦截请求时检查的不同（DNS 重绑定）；无法确定连接地址时同样拒绝。
+// data:、blob: 等不访问网络的响应不检查；来自缓存或 Service Worker 的响应没有连接地址，
+// 其请求已经过拦截检查，同样放行。
+func (g *requestGuard) checkResponse(resp *network.Response) error {
+	if resp == nil {
+		return nil
+	}
+	if resp.RemoteIPAddress == "" && (resp.FromDiskCache || resp.FromPrefetchCache || resp.FromServiceWorker) {
+		return nil
+	}
+	u, err := url.Parse(resp.URL)
+	if err != nil {
+		return &urlpolicy.Violation{URL: resp.URL, Reason: "URL 格式无效"}
+	}
+	if scheme := strings.ToLower(u.Scheme); scheme != "http" && scheme != "https" {
+		return nil
+	}
+	if reason := g.policy.CheckIP(net.ParseIP(resp.RemoteIPAddress)); reason != "" {
+		return &urlpolicy.Violation{URL: resp.URL, Reason: reason}
+	}
+	return nil
+}
+
+// block 记录第一个违规连接并关闭标签页
+func (g *requestGuard) block(err error) {
+	g.mu.Lock()
+	if g.blockedErr == nil {
+		g.blockedErr = err
+	}
+	g.mu.Unlock()
+	// 在事件回调中关闭标签页会等待事件处理结束，放到单独的 goroutine 中
+	go g.abort()
+}
+
+// blocked 返回第一个违规连接的原因
+func (g *requestGuard) blocked() error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.blockedErr
+}
+
+// violation 返回最近一次被拒绝的文档请求的原因
+func (g *requestGuard) violation() error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.documentViolation
+}
@@ -0,0 +1,40 @@
+package screenshot
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/chromedp/cdproto/network"
+
+	"github.com/gotoailab/snapup/internal/urlpolicy"
+)
+
+func TestCheckResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		resp    *network.Response
+		blocked bool
+	}{
+		{"公网地址", &network.Response{URL: "https://example.com/", RemoteIPAddress: "93.184.216.34"}, false},
+		{"私有地址", &network.Response{URL: "https://example.com/", RemoteIPAddress: "10.0.0.1"}, true},
+		{"IPv6 私有地址", &network.Response{URL: "https://example.com/", RemoteIPAddress: "fd00::1"}, true},
+		{"NAT64 地址", &network.Response{URL: "https://example.com/", RemoteIPAddress: "64:ff9b::7f00:1"}, true},
+		{"无法确定连接地址", &network.Response{URL: "https://example.com/"}, true},
+		{"磁盘缓存", &network.Response{URL: "https://example.com/a.css", FromDiskCache: true}, false},
+		{"预取缓存", &network.Response{URL: "https://example.com/b", FromPrefetchCache: true}, false},
+		{"Service Worker", &network.Response{URL: "https://example.com/c", FromServiceWorker: true}, false},
+		{"缓存响应仍检查已知的连接地址", &network.Response{URL: "https://example.com/d", FromDiskCache: true, RemoteIPAddress: "127.0.0.1"}, true},
+		{"data URL", &network.Response{URL: "data:image/png;base64,AAAA"}, false},
+		{"blob URL", &network.Response{URL: "blob:https://example.com/1"}, false},
+	}
+	g := &requestGuard{policy: urlpolicy.New(urlpolicy.Config{})}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := g.checkResponse(tt.resp)
+			var v *urlpolicy.Violation
+			if got := errors.As(err, &v); got != tt.blocked {
+				t.Fatalf("checkResponse = %v, want blocked = %v", err, tt.blocked)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package screenshot
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// documentResponseTimeout 导航提交后等待主文档响应事件的最长时间
+const documentResponseTimeout = 2 * time.Second
+
+// pageWatcher 跟踪页面的 load 事件、进行中的网络请求和文档响应
+type pageWatcher struct {
+	mu         sync.Mutex
+	inflight   map[network.RequestID]struct{}
+	lastChange time.Time
+	loaded     chan struct{}
+	loadOnce   sync.Once
+
+	// responses 按 LoaderID 记录文档响应，changed 在每次记录后关闭并重建
+	responses map[cdp.LoaderID]*network.Response
+	changed   chan struct{}
+
+	// response 本次导航的主文档响应，没有收到响应（如 data: URL）时为 nil
+	response *network.Response
+
+	// navStart 开始导航的时间，loadedAt 触发 load 事件的时间
+	navStart time.Time
+	loadedAt time.Time
+}
+
+// watchPage 在标签页上注册事件监听，需在导航之前调用
+func watchPage(ctx context.Context) *pageWatcher {
+	w := &pageWatcher{
+		inflight:   make(map[network.RequestID]struct{}),
+		lastChange: time.Now(),
+		loaded:     make(chan struct{}),
+		responses:  make(map[cdp.LoaderID]*network.Response),
+		changed:    make(chan struct{}),
+	}
+
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *network.EventRequestWillBeSent:
+			w.track(ev.RequestID, true)
+		case *network.EventLoadingFinished:
+			w.track(ev.RequestID, false)
+		case *network.EventLoadingFailed:
+			w.track(ev.RequestID, false)
+		case *network.EventResponseReceived:
+			if ev.Type == network.ResourceTypeDocument {
+				w.recordResponse(ev.LoaderID, ev.Response)
+			}
+		case *page.EventLoadEventFired:
+			w.loadOnce.Do(func() {
+				w.mu.Lock()
+				w.loadedAt = time.Now()
+				w.mu.Unlock()
+				close(w.loaded)
+			})
+		}
+	})
+	return w
+}
+
+// track 记录请求开始或结束
+func (w *pageWatcher) track(id network.RequestID, started bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if started {
+		w.inflight[id] = struct{}{}
+	} else {
+		delete(w.inflight, id)
+	}
+	w.lastChange = time.Now()
+}
+
+// idleFor 判断网络是否已空闲了至少 d
+func (w *pageWatcher) idleFor(d time.Duration) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return len(w.inflight) == 0 && time.Since(w.lastChange) >= d
+}
+
+// loadTime 返回从开始导航到 load 事件的耗时，尚未触发 load 时返回 0
+func (w *pageWatcher) loadTime() time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.loadedAt.IsZero() || w.navStart.IsZero() {
+		return 0
+	}
+	return w.loadedAt.Sub(w.navStart)
+}
+
+// recordResponse 记录文档响应并通知等待者
+func (w *pageWatcher) recordResponse(loaderID cdp.LoaderID, resp *network.Response) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.responses[loaderID] = resp
+	close(w.changed)
+	w.changed = make(chan struct{})
+}
+
+// documentResponse 等待指定导航的文档响应，超时或 ctx 结束时返回 nil
+func (w *pageWatcher) documentResponse(ctx context.Context, loaderID cdp.LoaderID, timeout time.Duration) *network.Response {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		w.mu.Lock()
+		resp, changed := w.responses[loaderID], w.changed
+		w.mu.Unlock()
+		if resp != nil {
+			return resp
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// navigate 发起导航，导航提交并收到主文档响应后立即返回，不等待 load 事件
+func navigate(w *pageWatcher, url string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		w.mu.Lock()
+		w.navStart = time.Now()
+		w.mu.Unlock()
+
+		_, loaderID, errorText, err := page.Navigate(url).Do(ctx)
+		if err != nil {
+			return err
+		}
+		if errorText != "" {
+			return &navigationError{text: errorText}
+		}
+		w.response = w.documentResponse(ctx, loaderID, documentResponseTimeout)
+		return nil
+	})
+}
@@ -0,0 +1,379 @@
+package screenshot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/chromedp"
+)
+
+// PoolConfig 浏览器池配置
+type PoolConfig struct {
+	Size        int    // 常驻浏览器数量
+	MaxUses     int    // 单个浏览器最多服务的请求数，超过后回收重建（0 表示不限制）
+	ChromeWSURL string // 远程 Chrome 地址，为空时启动本地 Chrome
+}
+
+// PoolStats 浏览器池统计信息
+type PoolStats struct {
+	Size     int   `json:"size"`
+	Started  int   `json:"started"`  // 当前已启动的浏览器数量
+	Idle     int   `json:"idle"`     // 空闲浏览器数量
+	InUse    int   `json:"in_use"`   // 正在使用的浏览器数量
+	Waiting  int   `json:"waiting"`  // 等待获取浏览器的请求数
+	Launches int64 `json:"launches"` // 累计启动次数
+	Recycled int64 `json:"recycled"` // 因达到使用上限而回收的次数
+	Crashed  int64 `json:"crashed"`  // 因崩溃而重建的次数
+	Leases   int64 `json:"leases"`   // 累计借出次数
+}
+
+// pooledBrowser 池中的单个浏览器实例
+type pooledBrowser struct {
+	ctx         context.Context
+	cancel      context.CancelFunc
+	allocCancel context.CancelFunc
+	uses        int
+}
+
+// alive 判断浏览器是否仍然可用
+func (b *pooledBrowser) alive() bool {
+	return b != nil && b.ctx.Err() == nil
+}
+
+// responsive 向浏览器发送一条轻量命令，确认其仍能响应
+func (b *pooledBrowser) responsive() bool {
+	if !b.alive() {
+		return false
+	}
+	c := chromedp.FromContext(b.ctx)
+	if c == nil || c.Browser == nil {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(b.ctx, 2*time.Second)
+	defer cancel()
+
+	_, _, _, _, _, err := browser.GetVersion().Do(cdp.WithExecutor(ctx, c.Browser))
+	return err == nil
+}
+
+// close 关闭浏览器并释放分配器
+func (b *pooledBrowser) close() {
+	b.cancel()
+	b.allocCancel()
+}
+
+// BrowserPool 预热的 Chrome 浏览器池
+//
+// 每个槽位持有一个浏览器实例，按需启动并在请求之间复用。每次借出时
+// 在该浏览器上创建独立的无痕上下文（BrowserContext），请求之间不共享
+// Cookie、缓存和存储。
+type BrowserPool struct {
+	config PoolConfig
+
+	// slots 中的元素为 nil 表示该槽位尚未启动浏览器
+	slots chan *pooledBrowser
+	done  chan struct{} // 池关闭时关闭，唤醒等待中的 Acquire
+
+	mu       sync.Mutex
+	started  int
+	inUse    int
+	waiting  int
+	launches int64
+	recycled int64
+	crashed  int64
+	leases   int64
+	closed   bool
+}
+
+// NewBrowserPool 创建浏览器池
+func NewBrowserPool(config PoolConfig) *BrowserPool {
+	if config.Size <= 0 {
+		config.Size = 1
+	}
+
+	p := &BrowserPool{
+		config: config,
+		slots:  make(chan *pooledBrowser, config.Size),
+		done:   make(chan struct{}),
+	}
+	for i := 0; i < config.Size; i++ {
+		p.slots <- nil
+	}
+	return p
+}
+
+// PoolConfigFromEnv 从环境变量读取浏览器池配置
+func PoolConfigFromEnv() PoolConfig {
+	return PoolConfig{
+		Size:        envInt("CHROME_POOL_SIZE", 2),
+		MaxUses:     envInt("CHROME_POOL_MAX_USES", 50),
+		ChromeWSURL: os.Getenv("CHROME_WS_URL"),
+	}
+}
+
+// BrowserLease 借出的浏览器
+type BrowserLease struct {
+	pool    *BrowserPool
+	browser *pooledBrowser
+	failed  bool
+}
+
+// NewTab 在借出的浏览器上创建一个独立的无痕标签页。
+// 返回的上下文会在 ctx 结束时一并取消，返回的取消函数可以多次调用。
+func (l *BrowserLease) NewTab(ctx context.Context) (context.Context, context.CancelFunc) {
+	tabCtx, tabCancel := chromedp.NewContext(l.browser.ctx, chromedp.WithNewBrowserContext())
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			tabCancel()
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+	return tabCtx, func() {
+		once.Do(func() {
+			close(done)
+			tabCancel()
+		})
+	}
+}
+
+// MarkFailed 标记本次使用中浏览器可能已损坏，归还时会检查并重建
+func (l *BrowserLease) MarkFailed() {
+	l.failed = true
+}
+
+// Release 归还浏览器
+func (l *BrowserLease) Release() {
+	l.pool.release(l)
+}
+
+// Acquire 从池中借出一个浏览器，必要时启动新的浏览器
+func (p *BrowserPool) Acquire(ctx context.Context) (*BrowserLease, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("浏览器池已关闭")
+	}
+	p.waiting++
+	p.mu.Unlock()
+
+	var b *pooledBrowser
+	select {
+	case b = <-p.slots:
+	case <-ctx.Done():
+		p.mu.Lock()
+		p.waiting--
+		p.mu.Unlock()
+		return nil, ctx.Err()
+	case <-p.done:
+		p.mu.Lock()
+		p.waiting--
+		p.mu.Unlock()
+		return nil, fmt.Errorf("浏览器池已关闭")
+	}
+
+	p.mu.Lock()
+	p.waiting--
+	if p.closed {
+		// 与 Close 同时取到的槽位不再借出
+		if b != nil {
+			p.started--
+		}
+		p.mu.Unlock()
+		if b != nil {
+			b.close()
+		}
+		return nil, fmt.Errorf("浏览器池已关闭")
+	}
+	p.inUse++
+	p.leases++
+	p.mu.Unlock()
+
+	if b != nil && !b.alive() {
+		log.Printf("浏览器已崩溃，重新启动")
+		b.close()
+		b = nil
+		p.mu.Lock()
+		p.started--
+		p.crashed++
+		p.mu.Unlock()
+	}
+
+	if b == nil {
+		var err error
+		b, err = p.launch(ctx)
+		if err != nil {
+			p.mu.Lock()
+			p.inUse--
+			p.mu.Unlock()
+			p.slots <- nil
+			return nil, err
+		}
+	}
+
+	b.uses++
+	return &BrowserLease{pool: p, browser: b}, nil
+}
+
+// launch 启动一个新的浏览器实例
+func (p *BrowserPool) launch(ctx context.Context) (*pooledBrowser, error) {
+	var allocCtx context.Context
+	var allocCancel context.CancelFunc
+
+	if p.config.ChromeWSURL != "" {
+		// 使用远程 Chrome（Docker 容器）
+		allocCtx, allocCancel = chromedp.NewRemoteAllocator(context.Background(), p.config.ChromeWSURL)
+	} else {
+		// 使用本地 Chrome
+		opts := append(chromedp.DefaultExecAllocatorOptions[:],
+			chromedp.Flag("headless", true),
+			chromedp.Flag("disable-gpu", true),
+			chromedp.Flag("no-sandbox", true),
+			chromedp.Flag("disable-dev-shm-usage", true),
+			chromedp.Flag("disable-setuid-sandbox", true),
+			chromedp.WindowSize(1920, 1080),
+		)
+		allocCtx, allocCancel = chromedp.NewExecAllocator(context.Background(), opts...)
+	}
+
+	browserCtx, cancel := chromedp.NewContext(allocCtx)
+
+	// 首次 Run 会真正启动（或连接）浏览器，不能给 browserCtx 本身加超时，
+	// 否则超时后整个浏览器都会被关闭
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- chromedp.Run(browserCtx)
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			cancel()
+			allocCancel()
+			return nil, fmt.Errorf("启动浏览器失败: %w", err)
+		}
+	case <-ctx.Done():
+		cancel()
+		allocCancel()
+		return nil, fmt.Errorf("启动浏览器失败: %w", ctx.Err())
+	}
+
+	p.mu.Lock()
+	p.started++
+	p.launches++
+	p.mu.Unlock()
+
+	return &pooledBrowser{
+		ctx:         browserCtx,
+		cancel:      cancel,
+		allocCancel: allocCancel,
+	}, nil
+}
+
+// release 归还浏览器，按需回收
+func (p *BrowserPool) release(l *BrowserLease) {
+	b := l.browser
+
+	// 截图失败时确认浏览器能否响应，最长需要 2 秒，不能持有锁，否则会阻塞其他请求获取和归还浏览器
+	p.mu.Lock()
+	probe := l.failed && !p.closed
+	p.mu.Unlock()
+	responsive := !probe || b.responsive()
+
+	p.mu.Lock()
+	p.inUse--
+	closed := p.closed
+	switch {
+	case closed:
+		p.started--
+	case !b.alive():
+		p.started--
+		p.crashed++
+	case !responsive:
+		p.started--
+		p.crashed++
+	case p.config.MaxUses > 0 && b.uses >= p.config.MaxUses:
+		p.started--
+		p.recycled++
+	default:
+		p.mu.Unlock()
+		p.slots <- b
+		return
+	}
+	p.mu.Unlock()
+
+	b.close()
+	if !closed {
+		p.slots <- nil
+	}
+}
+
+// Stats 返回浏览器池统计信息
+func (p *BrowserPool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return PoolStats{
+		Size:     p.config.Size,
+		Started:  p.started,
+		Idle:     p.started - p.inUse,
+		InUse:    p.inUse,
+		Waiting:  p.waiting,
+		Launches: p.launches,
+		Recycled: p.recycled,
+		Crashed:  p.crashed,
+		Leases:   p.leases,
+	}
+}
+
+// Close 关闭池中所有空闲浏览器，正在使用的浏览器在归还时关闭，等待中的 Acquire 立即返回错误
+func (p *BrowserPool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+	p.mu.Unlock()
+
+	for {
+		select {
+		case b := <-p.slots:
+			if b != nil {
+				b.close()
+				p.mu.Lock()
+				p.started--
+				p.mu.Unlock()
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// envInt 读取整数类型的环境变量
+func envInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("环境变量 %s 不是有效的整数: %q，使用默认值 %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
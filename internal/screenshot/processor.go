@@ -2,31 +2,46 @@ package screenshot
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
-	"image/png"
-	"math"
+	_ "image/jpeg"
+	_ "image/png"
+	"sync"
 
 	"github.com/gotoailab/snapup/internal/models"
 )
 
 // Processor 图片处理器接口
 type Processor interface {
-	Process(data []byte, style models.MockupStyle, background string) ([]byte, error)
+	Process(ctx context.Context, data []byte, req models.ScreenshotRequest, device models.DeviceConfig) ([]byte, error)
+	Composite(ctx context.Context, shots []CompositeShot, opts CompositeOptions) ([]byte, image.Point, error)
 }
 
 // ImageProcessor 图片处理器
-type ImageProcessor struct{}
+type ImageProcessor struct {
+	webp WebPEncoder
+
+	mu     sync.RWMutex
+	frames map[string]DeviceFrame
+}
 
 // NewImageProcessor 创建图片处理器
 func NewImageProcessor() *ImageProcessor {
-	return &ImageProcessor{}
+	frames := make(map[string]DeviceFrame, len(builtinFrames))
+	for name, frame := range builtinFrames {
+		frames[name] = frame
+	}
+
+	return &ImageProcessor{
+		frames: frames,
+	}
 }
 
-// Process 处理图片，应用样式
-func (p *ImageProcessor) Process(data []byte, style models.MockupStyle, bgColor string) ([]byte, error) {
+// Process 处理图片，应用样式并按请求的格式和质量编码
+func (p *ImageProcessor) Process(ctx context.Context, data []byte, req models.ScreenshotRequest, device models.DeviceConfig) ([]byte, error) {
 	// 解码原始截图
 	img, _, err := image.Decode(bytes.NewReader(data))
 	if err != nil {
@@ -35,58 +50,34 @@ func (p *ImageProcessor) Process(data []byte, style models.MockupStyle, bgColor
 
 	var resultImg image.Image
 
-	switch style {
+	switch req.Style {
 	case models.StyleNone:
 		resultImg = img
 	case models.StyleGlass:
-		resultImg = p.applyGlassStyle(img, bgColor)
+		resultImg, err = p.applyGlassStyle(img, req.Background, req.Glass)
+		if err != nil {
+			return nil, err
+		}
 	case models.StyleDevice:
-		resultImg = p.applyDeviceStyle(img, bgColor)
+		resultImg = p.applyDeviceStyle(img, req.Background, device)
 	case models.StyleFloating:
-		resultImg = p.applyFloatingStyle(img, bgColor)
+		resultImg = p.applyFloatingStyle(img, req.Background, req.Shadow)
 	default:
 		resultImg = img
 	}
 
-	// 编码为 PNG
-	var buf bytes.Buffer
-	if err := png.Encode(&buf, resultImg); err != nil {
-		return nil, fmt.Errorf("编码图片失败: %w", err)
-	}
-
-	return buf.Bytes(), nil
+	return encodeImage(ctx, resultImg, req.Format, req.Quality, p.webp)
 }
 
-// applyGlassStyle 应用玻璃模糊边缘样式
-func (p *ImageProcessor) applyGlassStyle(img image.Image, bgColor string) image.Image {
-	bounds := img.Bounds()
-	padding := 40
-	borderRadius := 20
-
-	newWidth := bounds.Dx() + padding*2
-	newHeight := bounds.Dy() + padding*2
-
-	result := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
-
-	// 填充背景
-	bg := p.parseColor(bgColor, color.RGBA{R: 240, G: 242, B: 245, A: 255})
-	draw.Draw(result, result.Bounds(), &image.Uniform{bg}, image.Point{}, draw.Src)
-
-	// 绘制半透明边框（模拟玻璃效果）
-	borderColor := color.RGBA{R: 255, G: 255, B: 255, A: 100}
-	p.drawRoundedRect(result, padding-5, padding-5, bounds.Dx()+10, bounds.Dy()+10, borderRadius+5, borderColor)
-
-	// 绘制原图
-	draw.Draw(result, image.Rect(padding, padding, padding+bounds.Dx(), padding+bounds.Dy()), img, bounds.Min, draw.Over)
-
-	return result
-}
+// applyDeviceStyle 应用设备包裹样式，按设备类型选择对应的外框
+func (p *ImageProcessor) applyDeviceStyle(img image.Image, bgColor string, device models.DeviceConfig) image.Image {
+	framed := renderDeviceFrame(img, p.frameFor(device))
+	bounds := framed.Bounds()
 
-// applyDeviceStyle 应用设备包裹样式
-func (p *ImageProcessor) applyDeviceStyle(img image.Image, bgColor string) image.Image {
-	bounds := img.Bounds()
 	padding := 60
-	deviceBorder := 8
+	if scaled := bounds.Dx() * 6 / 100; scaled > padding {
+		padding = scaled
+	}
 
 	newWidth := bounds.Dx() + padding*2
 	newHeight := bounds.Dy() + padding*2
@@ -97,22 +88,30 @@ func (p *ImageProcessor) applyDeviceStyle(img image.Image, bgColor string) image
 	bg := p.parseColor(bgColor, color.RGBA{R: 240, G: 242, B: 245, A: 255})
 	draw.Draw(result, result.Bounds(), &image.Uniform{bg}, image.Point{}, draw.Src)
 
-	// 绘制设备边框（模拟设备外壳）
-	deviceColor := color.RGBA{R: 50, G: 50, B: 50, A: 255}
-	p.drawRoundedRect(result, padding-deviceBorder, padding-deviceBorder,
-		bounds.Dx()+deviceBorder*2, bounds.Dy()+deviceBorder*2, 15, deviceColor)
-
-	// 绘制屏幕
-	draw.Draw(result, image.Rect(padding, padding, padding+bounds.Dx(), padding+bounds.Dy()), img, bounds.Min, draw.Over)
+	// 设备投影和设备本体
+	rect := image.Rect(padding, padding, padding+bounds.Dx(), padding+bounds.Dy())
+	drawMaskShadow(result, alphaMask(framed), rect.Min, ShadowOptions{
+		Blur:    float64(padding) * 0.6,
+		OffsetY: padding / 4,
+		Color:   color.RGBA{A: 255},
+		Opacity: 0.22,
+	})
+	draw.Draw(result, rect, framed, image.Point{}, draw.Over)
 
 	return result
 }
 
 // applyFloatingStyle 应用浮动阴影样式
-func (p *ImageProcessor) applyFloatingStyle(img image.Image, bgColor string) image.Image {
+func (p *ImageProcessor) applyFloatingStyle(img image.Image, bgColor string, shadow *models.ShadowSpec) image.Image {
 	bounds := img.Bounds()
+	borderRadius := 10
+	opts := p.shadowOptionsFromRequest(shadow)
+
+	// 画布边距需要容纳阴影的模糊范围和偏移
 	padding := 50
-	shadowOffset := 20
+	if extent := opts.extent(); extent > padding {
+		padding = extent
+	}
 
 	newWidth := bounds.Dx() + padding*2
 	newHeight := bounds.Dy() + padding*2
@@ -124,26 +123,15 @@ func (p *ImageProcessor) applyFloatingStyle(img image.Image, bgColor string) ima
 	draw.Draw(result, result.Bounds(), &image.Uniform{bg}, image.Point{}, draw.Src)
 
 	// 绘制阴影
-	shadowColor := color.RGBA{R: 0, G: 0, B: 0, A: 30}
-	for i := 0; i < shadowOffset; i++ {
-		alpha := uint8(30 * (1 - float64(i)/float64(shadowOffset)))
-		shadowColor.A = alpha
-		p.drawRoundedRect(result, padding+i, padding+i+shadowOffset/2, bounds.Dx(), bounds.Dy(), 10, shadowColor)
-	}
+	rect := image.Rect(padding, padding, padding+bounds.Dx(), padding+bounds.Dy())
+	drawShadow(result, rect, float64(borderRadius), opts)
 
-	// 绘制原图
-	draw.Draw(result, image.Rect(padding, padding, padding+bounds.Dx(), padding+bounds.Dy()), img, bounds.Min, draw.Over)
+	// 绘制圆角裁剪后的原图
+	drawRoundedImage(result, rect, img, float64(borderRadius))
 
 	return result
 }
 
-// drawRoundedRect 绘制圆角矩形
-func (p *ImageProcessor) drawRoundedRect(img *image.RGBA, x, y, w, h, r int, c color.Color) {
-	// 简化版圆角矩形，使用直角代替（完整实现需要更复杂的算法）
-	rect := image.Rect(x, y, x+w, y+h)
-	draw.Draw(img, rect, &image.Uniform{c}, image.Point{}, draw.Over)
-}
-
 // parseColor 解析颜色字符串
 func (p *ImageProcessor) parseColor(colorStr string, defaultColor color.RGBA) color.RGBA {
 	if colorStr == "" {
@@ -174,8 +162,3 @@ func (p *ImageProcessor) parseColor(colorStr string, defaultColor color.RGBA) co
 
 	return defaultColor
 }
-
-// Helper function to calculate distance
-func distance(x1, y1, x2, y2 float64) float64 {
-	return math.Sqrt((x2-x1)*(x2-x1) + (y2-y1)*(y2-y1))
-}
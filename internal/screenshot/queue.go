@@ -0,0 +1,284 @@
+package screenshot
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gotoailab/snapup/internal/models"
+)
+
+type clientKey struct{}
+
+// WithClient 在 context 中标记发起请求的客户端（如 API Key 名称或 IP），用于按客户端限制并发截图数
+func WithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// ClientFromContext 返回发起请求的客户端，未标记时返回空字符串
+func ClientFromContext(ctx context.Context) string {
+	client, _ := ctx.Value(clientKey{}).(string)
+	return client
+}
+
+type queueObserverKey struct{}
+
+// WithQueueObserver 在 context 中注册排队进度回调：入队和排队位置变化时以当前位置（从 1 开始）调用，
+// 开始执行时以 0 调用。回调在队列锁内执行，不能阻塞，也不能再调用截图服务。
+func WithQueueObserver(ctx context.Context, observe func(position int)) context.Context {
+	return context.WithValue(ctx, queueObserverKey{}, observe)
+}
+
+// queueObserverFromContext 返回注册的排队进度回调，未注册时返回空操作
+func queueObserverFromContext(ctx context.Context) func(int) {
+	if observe, ok := ctx.Value(queueObserverKey{}).(func(int)); ok {
+		return observe
+	}
+	return func(int) {}
+}
+
+type queueWaitKey struct{}
+
+// WithQueueWait 在 context 中标记截图在客户端达到并发上限或队列已满时排队等待，而不是立即拒绝。
+// 用于已被接受的异步任务：任务数量在提交时已经受限，任务中的截图依次执行即可。
+func WithQueueWait(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queueWaitKey{}, true)
+}
+
+// queueWaitFromContext 截图是否应排队等待而不是被拒绝
+func queueWaitFromContext(ctx context.Context) bool {
+	wait, _ := ctx.Value(queueWaitKey{}).(bool)
+	return wait
+}
+
+// QueueConfig 截图队列配置
+type QueueConfig struct {
+	Workers      int // 同时进行的截图数，0 表示不限制
+	Capacity     int // 最多排队等待的请求数，队列已满时拒绝新请求
+	MaxPerClient int // 每个客户端同时进行和排队中的请求数上限，0 表示不限制
+}
+
+// QueueConfigFromEnv 从环境变量读取截图队列配置，同时进行的截图数默认与浏览器池大小相同
+func QueueConfigFromEnv() QueueConfig {
+	return QueueConfig{
+		Workers:      envInt("MAX_CONCURRENT_CAPTURES", PoolConfigFromEnv().Size),
+		Capacity:     envInt("CAPTURE_QUEUE_SIZE", 100),
+		MaxPerClient: envInt("MAX_CONCURRENT_CAPTURES_PER_CLIENT", 4),
+	}
+}
+
+// QueueStats 截图队列统计信息
+type QueueStats struct {
+	Workers  int `json:"workers"`
+	Running  int `json:"running"`  // 正在进行的截图数
+	Waiting  int `json:"waiting"`  // 排队中的请求数
+	Capacity int `json:"capacity"` // 队列容量
+}
+
+// captureQueue 有界的先进先出截图队列。最多 Workers 个请求同时执行，
+// 其余请求按到达顺序排队，队列已满或超出客户端上限时直接拒绝，由客户端稍后重试。
+type captureQueue struct {
+	config QueueConfig
+
+	mu        sync.Mutex
+	running   int
+	waiting   *list.List // *queueTicket
+	perClient map[string]int
+	// clientWaiters 因达到客户端上限而等待的请求，客户端的请求结束时按先后顺序唤醒
+	clientWaiters map[string][]chan struct{}
+}
+
+// queueTicket 一个请求在队列中的凭据，执行完成后必须调用 release
+type queueTicket struct {
+	queue    *captureQueue
+	client   string
+	ready    chan struct{}
+	elem     *list.Element // 排队中时指向队列中的元素，开始执行后为 nil
+	position int           // 入队时的排队位置
+	wait     time.Duration // 排队等待时间
+	observe  func(int)
+	once     sync.Once
+}
+
+// newCaptureQueue 创建截图队列
+func newCaptureQueue(config QueueConfig) *captureQueue {
+	return &captureQueue{
+		config:        config,
+		waiting:       list.New(),
+		perClient:     make(map[string]int),
+		clientWaiters: make(map[string][]chan struct{}),
+	}
+}
+
+// acquire 为客户端排队等待执行名额，ctx 结束时放弃排队。
+// client 为空（如 MCP 调用）时不受客户端上限限制。
+// ctx 由 WithQueueWait 标记时，达到客户端上限或队列已满也排队等待。
+func (q *captureQueue) acquire(ctx context.Context, client string) (*queueTicket, error) {
+	start := time.Now()
+	t := &queueTicket{queue: q, client: client, ready: make(chan struct{}), observe: queueObserverFromContext(ctx)}
+	wait := queueWaitFromContext(ctx)
+
+	q.mu.Lock()
+	for client != "" && q.config.MaxPerClient > 0 && q.perClient[client] >= q.config.MaxPerClient {
+		if !wait {
+			q.mu.Unlock()
+			return nil, concurrencyLimited("同时进行的截图已达每个客户端的上限 %d，请等待之前的截图完成", q.config.MaxPerClient)
+		}
+		if err := q.waitForClient(ctx, client, start); err != nil {
+			return nil, err
+		}
+	}
+	if q.config.Workers <= 0 || (q.running < q.config.Workers && q.waiting.Len() == 0) {
+		q.running++
+		q.perClient[client]++
+		t.observe(0)
+		q.mu.Unlock()
+		return t, nil
+	}
+	if !wait && q.waiting.Len() >= q.config.Capacity {
+		q.mu.Unlock()
+		return nil, concurrencyLimited("服务器繁忙，截图队列已满（%d 个请求排队中），请稍后重试", q.config.Capacity)
+	}
+	t.elem = q.waiting.PushBack(t)
+	t.position = q.waiting.Len()
+	q.perClient[client]++
+	t.observe(t.position)
+	q.mu.Unlock()
+
+	select {
+	case <-t.ready:
+		t.wait = time.Since(start)
+		return t, nil
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	if t.elem != nil {
+		// 仍在排队，直接移出队列
+		q.waiting.Remove(t.elem)
+		t.elem = nil
+		q.decrementClient(client)
+		q.renumber()
+		q.mu.Unlock()
+	} else {
+		// 取消的同时刚好轮到执行，归还名额
+		q.mu.Unlock()
+		t.release()
+	}
+	return nil, queueCanceled(ctx, start)
+}
+
+// waitForClient 等待客户端的其他请求结束，需持有锁，返回时仍持有锁；ctx 结束时释放锁并返回错误
+func (q *captureQueue) waitForClient(ctx context.Context, client string, start time.Time) error {
+	ch := make(chan struct{})
+	q.clientWaiters[client] = append(q.clientWaiters[client], ch)
+	q.mu.Unlock()
+
+	select {
+	case <-ch:
+		q.mu.Lock()
+		return nil
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	waiters := q.clientWaiters[client]
+	removed := false
+	for i, c := range waiters {
+		if c == ch {
+			q.clientWaiters[client] = append(waiters[:i:i], waiters[i+1:]...)
+			removed = true
+			break
+		}
+	}
+	if len(q.clientWaiters[client]) == 0 {
+		delete(q.clientWaiters, client)
+	}
+	if !removed {
+		// 取消的同时刚好被唤醒，把机会让给下一个等待的请求
+		q.wakeClient(client)
+	}
+	q.mu.Unlock()
+	return queueCanceled(ctx, start)
+}
+
+// wakeClient 唤醒客户端最早等待的一个请求，需持有锁
+func (q *captureQueue) wakeClient(client string) {
+	waiters := q.clientWaiters[client]
+	if len(waiters) == 0 {
+		return
+	}
+	close(waiters[0])
+	if len(waiters) == 1 {
+		delete(q.clientWaiters, client)
+	} else {
+		q.clientWaiters[client] = waiters[1:]
+	}
+}
+
+// queueCanceled 返回排队期间 ctx 结束的错误：超时返回 queue_timeout，否则为取消
+func queueCanceled(ctx context.Context, start time.Time) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &requestError{
+			code: models.CodeQueueTimeout,
+			msg:  fmt.Sprintf("排队等待 %s 后请求超时，服务器繁忙，请稍后重试", time.Since(start).Round(time.Millisecond)),
+		}
+	}
+	return fmt.Errorf("排队时请求已取消: %w", ctx.Err())
+}
+
+// release 执行完成，归还名额并唤醒队首的请求，可重复调用
+func (t *queueTicket) release() {
+	t.once.Do(func() {
+		q := t.queue
+		q.mu.Lock()
+		defer q.mu.Unlock()
+
+		q.running--
+		q.decrementClient(t.client)
+		dispatched := false
+		for q.running < q.config.Workers && q.waiting.Len() > 0 {
+			next := q.waiting.Remove(q.waiting.Front()).(*queueTicket)
+			next.elem = nil
+			q.running++
+			next.observe(0)
+			close(next.ready)
+			dispatched = true
+		}
+		if dispatched {
+			q.renumber()
+		}
+	})
+}
+
+// renumber 通知排队中的请求其最新位置，需持有锁
+func (q *captureQueue) renumber() {
+	position := 1
+	for e := q.waiting.Front(); e != nil; e = e.Next() {
+		e.Value.(*queueTicket).observe(position)
+		position++
+	}
+}
+
+// decrementClient 减少客户端的请求计数并唤醒一个等待该客户端名额的请求，需持有锁
+func (q *captureQueue) decrementClient(client string) {
+	if q.perClient[client]--; q.perClient[client] <= 0 {
+		delete(q.perClient, client)
+	}
+	q.wakeClient(client)
+}
+
+// stats 返回队列统计信息
+func (q *captureQueue) stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return QueueStats{
+		Workers:  q.config.Workers,
+		Running:  q.running,
+		Waiting:  q.waiting.Len(),
+		Capacity: q.config.Capacity,
+	}
+}
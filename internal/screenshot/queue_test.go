@@ -0,0 +1,154 @@
+package screenshot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gotoailab/snapup/internal/models"
+)
+
+// acquireAsync 在后台排队，返回接收结果的 channel
+func acquireAsync(ctx context.Context, q *captureQueue, client string) <-chan *queueTicket {
+	ch := make(chan *queueTicket, 1)
+	go func() {
+		t, err := q.acquire(ctx, client)
+		if err != nil {
+			t = nil
+		}
+		ch <- t
+	}()
+	return ch
+}
+
+// waitQueued 等待队列中有 n 个排队的请求
+func waitQueued(t *testing.T, q *captureQueue, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for q.stats().Waiting != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("waiting = %d, want %d", q.stats().Waiting, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCaptureQueueLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  QueueConfig
+		clients []string // 依次提交、不释放的请求
+		wait    bool
+		code    models.ErrorCode // 最后一个请求的错误码，空表示应立即执行或排队成功
+	}{
+		{"不限制", QueueConfig{}, []string{"a", "a", "a"}, false, ""},
+		{"客户端达到上限时拒绝", QueueConfig{MaxPerClient: 2}, []string{"a", "a", "a"}, false, models.CodeConcurrencyLimit},
+		{"其他客户端不受影响", QueueConfig{MaxPerClient: 2}, []string{"a", "a", "b"}, false, ""},
+		{"未标记客户端不受上限限制", QueueConfig{MaxPerClient: 1}, []string{"", "", ""}, false, ""},
+		{"队列已满时拒绝", QueueConfig{Workers: 1, Capacity: 1}, []string{"a", "b", "c"}, false, models.CodeConcurrencyLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newCaptureQueue(tt.config)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			last := len(tt.clients) - 1
+			for i, client := range tt.clients[:last] {
+				if tt.config.Workers > 0 && i >= tt.config.Workers {
+					acquireAsync(ctx, q, client)
+					waitQueued(t, q, i-tt.config.Workers+1)
+					continue
+				}
+				if _, err := q.acquire(ctx, client); err != nil {
+					t.Fatalf("第 %d 个请求: %v", i, err)
+				}
+			}
+
+			short, cancelShort := context.WithTimeout(ctx, 50*time.Millisecond)
+			defer cancelShort()
+			_, err := q.acquire(short, tt.clients[last])
+			switch {
+			case tt.code == "" && err != nil && ErrorCode(err) != models.CodeQueueTimeout:
+				t.Fatalf("err = %v, want nil", err)
+			case tt.code != "" && ErrorCode(err) != tt.code:
+				t.Fatalf("code = %q, want %q (err = %v)", ErrorCode(err), tt.code, err)
+			}
+		})
+	}
+}
+
+func TestCaptureQueueFIFO(t *testing.T) {
+	q := newCaptureQueue(QueueConfig{Workers: 1, Capacity: 10})
+	ctx := context.Background()
+
+	first, err := q.acquire(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := acquireAsync(ctx, q, "b")
+	waitQueued(t, q, 1)
+	third := acquireAsync(ctx, q, "c")
+	waitQueued(t, q, 2)
+
+	first.release()
+	t2 := <-second
+	if t2 == nil || t2.position != 1 {
+		t.Fatalf("second = %+v", t2)
+	}
+	select {
+	case <-third:
+		t.Fatalf("第三个请求应继续排队")
+	case <-time.After(20 * time.Millisecond):
+	}
+	// 重复 release 不会多归还名额
+	first.release()
+	if s := q.stats(); s.Running != 1 || s.Waiting != 1 {
+		t.Fatalf("stats = %+v", s)
+	}
+
+	t2.release()
+	if t3 := <-third; t3 == nil || t3.position != 2 {
+		t.Fatalf("third = %+v", t3)
+	}
+}
+
+func TestCaptureQueueWaitForClient(t *testing.T) {
+	q := newCaptureQueue(QueueConfig{MaxPerClient: 1})
+	ctx := WithQueueWait(context.Background())
+
+	first, err := q.acquire(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := acquireAsync(ctx, q, "a")
+	select {
+	case <-second:
+		t.Fatalf("达到客户端上限时应等待")
+	case <-time.After(20 * time.Millisecond):
+	}
+	first.release()
+	if t2 := <-second; t2 == nil {
+		t.Fatalf("之前的请求结束后应开始执行")
+	}
+}
+
+func TestCaptureQueueTimeout(t *testing.T) {
+	q := newCaptureQueue(QueueConfig{Workers: 1, Capacity: 10})
+	if _, err := q.acquire(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := q.acquire(ctx, "b")
+	if ErrorCode(err) != models.CodeQueueTimeout {
+		t.Fatalf("code = %q, want %q", ErrorCode(err), models.CodeQueueTimeout)
+	}
+	if s := q.stats(); s.Waiting != 0 || s.Running != 1 {
+		t.Fatalf("超时的请求应移出队列: %+v", s)
+	}
+	if _, ok := q.perClient["b"]; ok {
+		t.Fatalf("超时的请求应归还客户端名额")
+	}
+}
@@ -3,10 +3,16 @@ package screenshot
 import (
 	"context"
 	"fmt"
+	"io"
+	"log"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/gotoailab/snapup/internal/models"
+	"github.com/gotoailab/snapup/internal/urlpolicy"
 
 	"github.com/google/uuid"
 )
@@ -14,7 +20,17 @@ import (
 // Service 截图服务
 type Service struct {
 	capturer  Capturer
+	processor Processor
+	devices   *models.DeviceRegistry
+	policy    *urlpolicy.Policy
+	queue     *captureQueue
+	baselines *baselineStore
 	outputDir string
+
+	defaultTimeout time.Duration // 请求未指定 timeout 时使用的超时时间
+	maxTimeout     time.Duration // 请求允许指定的最大超时时间
+	maxBatchSize   int           // 批量截图允许的最大组合数
+	maxCrawlPages  int           // 站点爬取允许的最大页面数
 }
 
 // NewService 创建截图服务
@@ -24,77 +40,385 @@ func NewService(outputDir string) *Service {
 		panic(fmt.Sprintf("创建输出目录失败: %v", err))
 	}
 
+	// 加载设备预设
+	devices, err := models.LoadDeviceRegistry(os.Getenv("DEVICES_FILE"))
+	if err != nil {
+		panic(fmt.Sprintf("加载设备预设失败: %v", err))
+	}
+
+	// 加载基线记录
+	baselines, err := loadBaselineStore(filepath.Join(outputDir, baselineFile))
+	if err != nil {
+		panic(fmt.Sprintf("加载基线记录失败: %v", err))
+	}
+
+	policy := urlpolicy.New(urlpolicy.ConfigFromEnv())
+	capturer := NewChromeCapture(policy)
+	processor := NewImageProcessor()
+	processor.webp = capturer
+	if dir := os.Getenv("DEVICE_FRAMES_DIR"); dir != "" {
+		if err := processor.LoadFrames(dir); err != nil {
+			log.Printf("加载设备外框失败: %v", err)
+		}
+	}
+
 	return &Service{
-		capturer:  NewChromeCapture(),
+		capturer:  capturer,
+		processor: processor,
+		devices:   devices,
+		policy:    policy,
+		queue:     newCaptureQueue(QueueConfigFromEnv()),
+		baselines: baselines,
 		outputDir: outputDir,
+
+		defaultTimeout: time.Duration(envInt("SCREENSHOT_TIMEOUT", 30)) * time.Second,
+		maxTimeout:     time.Duration(envInt("SCREENSHOT_MAX_TIMEOUT", 120)) * time.Second,
+		maxBatchSize:   envInt("BATCH_MAX_SIZE", 50),
+		maxCrawlPages:  envInt("CRAWL_MAX_PAGES", 200),
 	}
 }
 
 // TakeScreenshot 执行截图
 func (s *Service) TakeScreenshot(ctx context.Context, req models.ScreenshotRequest) (*models.ScreenshotResponse, error) {
-	// 验证请求
-	if err := s.validateRequest(&req); err != nil {
-		return &models.ScreenshotResponse{
-			Success: false,
-			Message: err.Error(),
-		}, nil
-	}
+	// 整个请求（排队、截图、处理和保存）共用一个超时时间
+	ctx, cancel := s.withTimeout(ctx, req.Timeout)
+	defer cancel()
 
-	// 执行截图
-	data, err := s.capturer.Capture(ctx, req)
-	if err != nil {
-		return &models.ScreenshotResponse{
-			Success: false,
-			Message: fmt.Sprintf("截图失败: %v", err),
-		}, nil
+	shot, resp := s.capture(ctx, req)
+	if resp != nil {
+		return resp, nil
 	}
+	defer shot.release()
+	req, device, timings := shot.req, shot.device, &shot.result.Timings
+	data := shot.result.Data
 
-	// 保存文件（不再处理样式，直接保存原始截图）
-	filename := s.generateFilename(req)
-	filepath := filepath.Join(s.outputDir, filename)
+	// 应用 mockup 样式
+	var err error
+	if req.Style != models.StyleNone {
+		processStart := time.Now()
+		data, err = s.processor.Process(ctx, data, req, device)
+		timings.Processing = time.Since(processStart).Milliseconds()
+		if err != nil {
+			return shot.fail(newPhaseError(ctx, PhaseProcessing, err)), nil
+		}
+	}
 
-	if err := os.WriteFile(filepath, data, 0644); err != nil {
-		return &models.ScreenshotResponse{
-			Success: false,
-			Message: fmt.Sprintf("保存文件失败: %v", err),
-		}, nil
+	// 保存文件
+	saveStart := time.Now()
+	filename := s.generateFilename(req, device)
+	if err := s.save(ctx, filename, data); err != nil {
+		return shot.fail(err), nil
 	}
+	timings.Save = time.Since(saveStart).Milliseconds()
+	timings.Total = time.Since(shot.start).Milliseconds()
 
 	return &models.ScreenshotResponse{
 		Success:  true,
 		Message:  "截图成功",
 		ImageURL: "/screenshots/" + filename,
 		Filename: filename,
+		Style:    req.Style,
+		Format:   req.Format,
+		Width:    device.Width,
+		Height:   device.Height,
+		Scale:    device.Scale,
+		Page:     shot.result.Page,
+		Timings:  timings,
+		Queue:    shot.queue,
 	}, nil
 }
 
+// capturedShot 截图阶段的结果
+type capturedShot struct {
+	req     models.ScreenshotRequest // 补全默认值后的请求
+	device  models.DeviceConfig      // 应用自定义视口后的设备配置
+	result  *CaptureResult
+	queue   *models.QueueInfo
+	start   time.Time
+	release func() // 归还队列名额
+}
+
+// fail 返回截图之后的阶段失败时的响应，包含已完成阶段的耗时和页面信息
+func (c *capturedShot) fail(err error) *models.ScreenshotResponse {
+	c.result.Timings.Total = time.Since(c.start).Milliseconds()
+	return &models.ScreenshotResponse{
+		Success: false,
+		Message: err.Error(),
+		Page:    c.result.Page,
+		Code:    ErrorCode(err),
+		Timings: &c.result.Timings,
+		Queue:   c.queue,
+	}
+}
+
+// failure 返回截图开始前失败时的响应
+func failure(err error) *models.ScreenshotResponse {
+	return &models.ScreenshotResponse{
+		Success: false,
+		Message: err.Error(),
+		Code:    ErrorCode(err),
+	}
+}
+
+// withTimeout 返回带请求超时的 context，timeout 为请求指定的毫秒数，0 表示使用默认值
+func (s *Service) withTimeout(ctx context.Context, timeout int) (context.Context, context.CancelFunc) {
+	d := s.defaultTimeout
+	if timeout > 0 {
+		d = time.Duration(timeout) * time.Millisecond
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// capture 验证请求、检查 URL 访问策略、排队并截图，需要套用样式时截取无损 PNG。
+// 失败时返回失败响应；成功时调用方处理完成后需调用 shot.release 归还队列名额。
+func (s *Service) capture(ctx context.Context, req models.ScreenshotRequest) (*capturedShot, *models.ScreenshotResponse) {
+	// 验证请求
+	if err := s.validateRequest(&req); err != nil {
+		return nil, failure(err)
+	}
+
+	preset, err := s.devices.Get(req.Device)
+	if err != nil {
+		return nil, failure(invalidRequest("%v", err))
+	}
+	device := req.ApplyOverrides(preset)
+
+	// 检查 URL 访问策略，重定向和子资源在截图时由浏览器拦截检查
+	if err := s.policy.Check(ctx, req.URL); err != nil {
+		return nil, failure(err)
+	}
+
+	// 排队等待执行名额，截图、处理和保存完成后释放
+	ticket, err := s.queue.acquire(ctx, ClientFromContext(ctx))
+	if err != nil {
+		return nil, failure(err)
+	}
+
+	captureReq := req
+	if req.Style != models.StyleNone {
+		captureReq.Format = models.FormatPNG
+	}
+
+	shot := &capturedShot{
+		req:     req,
+		device:  device,
+		queue:   &models.QueueInfo{Position: ticket.position, Wait: ticket.wait.Milliseconds()},
+		start:   time.Now(),
+		release: ticket.release,
+	}
+	shot.result, err = s.capturer.Capture(ctx, captureReq, device)
+	if err != nil {
+		ticket.release()
+		return nil, shot.fail(err)
+	}
+	return shot, nil
+}
+
+// save 将图片保存到输出目录
+func (s *Service) save(ctx context.Context, filename string, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return newPhaseError(ctx, PhaseSave, err)
+	}
+	if err := os.WriteFile(filepath.Join(s.outputDir, filename), data, 0644); err != nil {
+		return newPhaseError(ctx, PhaseSave, err)
+	}
+	return nil
+}
+
+// Validate 检查截图请求的参数、设备和 URL 访问策略，不执行截图。
+// 用于异步任务和批量截图在提交前提前拒绝无效请求。
+func (s *Service) Validate(ctx context.Context, req models.ScreenshotRequest) error {
+	if err := s.validateRequest(&req); err != nil {
+		return err
+	}
+	if _, err := s.devices.Get(req.Device); err != nil {
+		return invalidRequest("%v", err)
+	}
+	return s.policy.Check(ctx, req.URL)
+}
+
+// MaxTimeout 返回请求允许指定的最大超时时间
+func (s *Service) MaxTimeout() time.Duration {
+	return s.maxTimeout
+}
+
+// QueueStats 返回截图队列统计信息
+func (s *Service) QueueStats() QueueStats {
+	return s.queue.stats()
+}
+
+// Devices 返回设备预设注册表
+func (s *Service) Devices() *models.DeviceRegistry {
+	return s.devices
+}
+
+// PoolStats 返回浏览器池统计信息，捕获器不使用浏览器池时返回 false
+func (s *Service) PoolStats() (PoolStats, bool) {
+	if c, ok := s.capturer.(interface{ Stats() PoolStats }); ok {
+		return c.Stats(), true
+	}
+	return PoolStats{}, false
+}
+
+// Close 释放捕获器持有的资源（如浏览器池）
+func (s *Service) Close() error {
+	if c, ok := s.capturer.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
 // validateRequest 验证请求
 func (s *Service) validateRequest(req *models.ScreenshotRequest) error {
 	if req.URL == "" {
-		return fmt.Errorf("URL 不能为空")
+		return invalidURL("URL 不能为空")
+	}
+	if u, err := url.Parse(req.URL); err != nil || u.Scheme == "" || ((u.Scheme == "http" || u.Scheme == "https") && u.Host == "") {
+		return invalidURL("无效的 URL: %s", req.URL)
 	}
 
 	// 设置默认值
 	if req.Device == "" {
 		req.Device = models.DeviceDesktop
 	}
-	if req.Style == "" {
+	switch req.Style {
+	case "":
 		req.Style = models.StyleNone
+	case models.StyleNone, models.StyleGlass, models.StyleDevice, models.StyleFloating:
+	default:
+		return invalidRequest("不支持的样式: %s", req.Style)
+	}
+	switch req.Format {
+	case "":
+		req.Format = models.FormatPNG
+	case "jpg":
+		req.Format = models.FormatJPEG
+	case models.FormatPNG, models.FormatJPEG, models.FormatWebP:
+	default:
+		return invalidRequest("不支持的图片格式: %s", req.Format)
 	}
 	if req.Quality == 0 {
 		req.Quality = 90
 	}
+	if req.Quality < 1 || req.Quality > 100 {
+		return invalidRequest("图片质量必须在 1-100 之间")
+	}
 	if req.Background == "" {
 		req.Background = "#f0f2f5"
 	}
+	if req.Width != 0 && (req.Width < models.MinViewportSize || req.Width > models.MaxViewportWidth) {
+		return invalidRequest("视口宽度必须在 %d-%d 之间", models.MinViewportSize, models.MaxViewportWidth)
+	}
+	if req.Height != 0 && (req.Height < models.MinViewportSize || req.Height > models.MaxViewportHeight) {
+		return invalidRequest("视口高度必须在 %d-%d 之间", models.MinViewportSize, models.MaxViewportHeight)
+	}
+	if req.DeviceScaleFactor != 0 && (req.DeviceScaleFactor < models.MinScaleFactor || req.DeviceScaleFactor > models.MaxScaleFactor) {
+		return invalidRequest("设备像素比必须在 %g-%g 之间", models.MinScaleFactor, models.MaxScaleFactor)
+	}
+	for i := range req.Wait {
+		if err := validateWaitCondition(&req.Wait[i]); err != nil {
+			return invalidRequest("等待条件 %d 无效: %v", i+1, err)
+		}
+	}
+	if req.Timeout < 0 || time.Duration(req.Timeout)*time.Millisecond > s.maxTimeout {
+		return invalidRequest("超时时间必须在 0-%d 毫秒之间", s.maxTimeout.Milliseconds())
+	}
+	if r := req.AcceptStatus; r != nil && (r.Min < 100 || r.Max > 599 || r.Min > r.Max) {
+		return invalidRequest("可接受的状态码范围无效: %d-%d", r.Min, r.Max)
+	}
+	if req.Selector != "" && req.Clip != nil {
+		return invalidRequest("selector 和 clip 不能同时指定")
+	}
+	if req.Padding < 0 || req.Padding > models.MaxElementPadding {
+		return invalidRequest("元素边距必须在 0-%d 之间", models.MaxElementPadding)
+	}
+	if c := req.Clip; c != nil {
+		if c.X < 0 || c.Y < 0 {
+			return invalidRequest("截取区域的坐标不能为负数")
+		}
+		if c.Width <= 0 || c.Height <= 0 || c.Width > models.MaxClipSize || c.Height > models.MaxClipSize {
+			return invalidRequest("截取区域的宽高必须在 1-%d 之间", models.MaxClipSize)
+		}
+	}
+	switch req.Orientation {
+	case "", models.OrientationPortrait, models.OrientationLandscape:
+	default:
+		return invalidRequest("不支持的屏幕方向: %s", req.Orientation)
+	}
+	if len(req.UserAgent) > 1024 {
+		return invalidRequest("User-Agent 长度不能超过 1024")
+	}
+	if shadow := req.Shadow; shadow != nil {
+		if shadow.Blur != nil && (*shadow.Blur < 0 || *shadow.Blur > 200) {
+			return invalidRequest("阴影模糊半径必须在 0-200 之间")
+		}
+		if shadow.Opacity != nil && (*shadow.Opacity < 0 || *shadow.Opacity > 1) {
+			return invalidRequest("阴影不透明度必须在 0-1 之间")
+		}
+		for _, v := range []struct {
+			name  string
+			value *int
+		}{{"水平偏移", shadow.OffsetX}, {"垂直偏移", shadow.OffsetY}, {"扩展距离", shadow.Spread}} {
+			if v.value != nil && (*v.value < -models.MaxShadowOffset || *v.value > models.MaxShadowOffset) {
+				return invalidRequest("阴影%s必须在 -%d 到 %d 之间", v.name, models.MaxShadowOffset, models.MaxShadowOffset)
+			}
+		}
+	}
+	if glass := req.Glass; glass != nil {
+		if glass.Blur != nil && (*glass.Blur < 0 || *glass.Blur > 200) {
+			return invalidRequest("玻璃背景模糊半径必须在 0-200 之间")
+		}
+		if glass.Opacity != nil && (*glass.Opacity < 0 || *glass.Opacity > 1) {
+			return invalidRequest("玻璃卡片不透明度必须在 0-1 之间")
+		}
+		if len(glass.BackgroundImage) > models.MaxBackgroundImageSize {
+			return invalidRequest("玻璃背景图片不能超过 %d 字节", models.MaxBackgroundImageSize)
+		}
+	}
+
+	return nil
+}
 
+// validateWaitCondition 验证等待条件并填充默认值
+func validateWaitCondition(cond *models.WaitCondition) error {
+	switch cond.Type {
+	case models.WaitLoad, models.WaitFonts:
+	case models.WaitNetworkIdle:
+		if cond.IdleTime == 0 {
+			cond.IdleTime = models.DefaultNetworkIdle
+		}
+		if cond.IdleTime < 0 || cond.IdleTime > models.MaxNetworkIdleTime {
+			return invalidRequest("网络空闲时间必须在 0-%d 毫秒之间", models.MaxNetworkIdleTime)
+		}
+	case models.WaitSelector, models.WaitExpression:
+		if strings.TrimSpace(cond.Value) == "" {
+			return invalidRequest("%s 条件需要指定 value", cond.Type)
+		}
+	default:
+		return invalidRequest("不支持的等待条件: %s", cond.Type)
+	}
+
+	if cond.Timeout == 0 {
+		cond.Timeout = models.DefaultWaitTimeout
+	}
+	if cond.Timeout < 0 || cond.Timeout > models.MaxWaitTimeout {
+		return invalidRequest("超时时间必须在 0-%d 毫秒之间", models.MaxWaitTimeout)
+	}
 	return nil
 }
 
-// generateFilename 生成文件名
-func (s *Service) generateFilename(req models.ScreenshotRequest) string {
+// generateFilename 生成文件名，自定义视口时附带实际尺寸
+func (s *Service) generateFilename(req models.ScreenshotRequest, device models.DeviceConfig) string {
 	id := uuid.New().String()
-	return fmt.Sprintf("screenshot_%s_%s.png", req.Device, id)
+	ext := req.Format.Extension()
+	label := string(req.Device)
+	if req.HasViewportOverride() {
+		label = fmt.Sprintf("%s_%dx%d", req.Device, device.Width, device.Height)
+	}
+	if req.Style != models.StyleNone {
+		return fmt.Sprintf("screenshot_%s_%s_%s%s", label, req.Style, id, ext)
+	}
+	return fmt.Sprintf("screenshot_%s_%s%s", label, id, ext)
 }
 
 // CleanupOldScreenshots 清理旧截图（可选功能）
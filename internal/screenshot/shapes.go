@@ -0,0 +1,149 @@
+package screenshot
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// roundedRectMask 生成抗锯齿的圆角矩形遮罩
+//
+// 每个像素的覆盖率由像素中心到圆角矩形边界的有向距离计算，
+// 边缘处在一个像素宽度内平滑过渡。
+func roundedRectMask(w, h int, r float64) *image.Alpha {
+	mask := image.NewAlpha(image.Rect(0, 0, w, h))
+	if w <= 0 || h <= 0 {
+		return mask
+	}
+
+	r = clampRadius(r, w, h)
+	halfW := float64(w) / 2
+	halfH := float64(h) / 2
+
+	for i := range mask.Pix {
+		mask.Pix[i] = 255
+	}
+
+	// 只有四个角需要计算覆盖率，其余区域完全不透明
+	corner := int(math.Ceil(r)) + 1
+	for y := 0; y < h; y++ {
+		if y >= corner && y < h-corner {
+			continue
+		}
+		py := float64(y) + 0.5
+		for x := 0; x < w; x++ {
+			if x >= corner && x < w-corner {
+				x = w - corner - 1
+				continue
+			}
+			px := float64(x) + 0.5
+			d := roundedRectDistance(px-halfW, py-halfH, halfW, halfH, r)
+			mask.Pix[y*mask.Stride+x] = coverage(d)
+		}
+	}
+
+	return mask
+}
+
+// roundedRectStrokeMask 生成抗锯齿的圆角矩形描边遮罩，描边沿边界向内绘制 width 像素
+func roundedRectStrokeMask(w, h int, r, width float64) *image.Alpha {
+	mask := image.NewAlpha(image.Rect(0, 0, w, h))
+	if w <= 0 || h <= 0 || width <= 0 {
+		return mask
+	}
+
+	r = clampRadius(r, w, h)
+	halfW := float64(w) / 2
+	halfH := float64(h) / 2
+
+	// 描边只出现在距离边界 band 以内的区域
+	band := int(math.Ceil(r+width)) + 1
+	for y := 0; y < h; y++ {
+		py := float64(y) + 0.5
+		edgeRow := y < band || y >= h-band
+		for x := 0; x < w; x++ {
+			if !edgeRow && x >= band && x < w-band {
+				x = w - band - 1
+				continue
+			}
+			px := float64(x) + 0.5
+			d := roundedRectDistance(px-halfW, py-halfH, halfW, halfH, r)
+			outer := float64(coverage(d)) / 255
+			inner := float64(coverage(-d-width)) / 255
+			mask.Pix[y*mask.Stride+x] = uint8(outer*inner*255 + 0.5)
+		}
+	}
+
+	return mask
+}
+
+// roundedRectDistance 计算点 (px, py)（相对矩形中心）到圆角矩形边界的有向距离，
+// 矩形内部为负值
+func roundedRectDistance(px, py, halfW, halfH, r float64) float64 {
+	qx := math.Abs(px) - (halfW - r)
+	qy := math.Abs(py) - (halfH - r)
+
+	outside := distance(0, 0, math.Max(qx, 0), math.Max(qy, 0))
+	inside := math.Min(math.Max(qx, qy), 0)
+	return outside + inside - r
+}
+
+// coverage 将有向距离转换为 0-255 的覆盖率
+func coverage(d float64) uint8 {
+	a := 0.5 - d
+	if a <= 0 {
+		return 0
+	}
+	if a >= 1 {
+		return 255
+	}
+	return uint8(a*255 + 0.5)
+}
+
+// clampRadius 限制圆角半径不超过短边的一半
+func clampRadius(r float64, w, h int) float64 {
+	limit := math.Min(float64(w), float64(h)) / 2
+	if r > limit {
+		return limit
+	}
+	if r < 0 {
+		return 0
+	}
+	return r
+}
+
+// fillRoundedRect 以抗锯齿方式填充圆角矩形
+func fillRoundedRect(dst draw.Image, rect image.Rectangle, r float64, c color.Color) {
+	mask := roundedRectMask(rect.Dx(), rect.Dy(), r)
+	draw.DrawMask(dst, rect, &image.Uniform{c}, image.Point{}, mask, image.Point{}, draw.Over)
+}
+
+// strokeRoundedRect 以抗锯齿方式绘制圆角矩形描边
+func strokeRoundedRect(dst draw.Image, rect image.Rectangle, r, width float64, c color.Color) {
+	mask := roundedRectStrokeMask(rect.Dx(), rect.Dy(), r, width)
+	draw.DrawMask(dst, rect, &image.Uniform{c}, image.Point{}, mask, image.Point{}, draw.Over)
+}
+
+// drawRoundedImage 将图片以圆角裁剪的方式绘制到目标区域
+func drawRoundedImage(dst draw.Image, rect image.Rectangle, src image.Image, r float64) {
+	mask := roundedRectMask(rect.Dx(), rect.Dy(), r)
+	draw.DrawMask(dst, rect, src, src.Bounds().Min, mask, image.Point{}, draw.Over)
+}
+
+// distance 计算两点之间的距离
+func distance(x1, y1, x2, y2 float64) float64 {
+	return math.Sqrt((x2-x1)*(x2-x1) + (y2-y1)*(y2-y1))
+}
+
+// alphaMask 提取图片的 alpha 通道作为遮罩
+func alphaMask(img *image.RGBA) *image.Alpha {
+	b := img.Bounds()
+	mask := image.NewAlpha(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			mask.Pix[y*mask.Stride+x] = img.Pix[img.PixOffset(b.Min.X+x, b.Min.Y+y)+3]
+		}
+	}
+	return mask
+}
@@ -0,0 +1,105 @@
+package screenshot
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gotoailab/snapup/internal/urlpolicy"
+)
+
+// sitemap 的读取限制
+const (
+	maxSitemapSize     = 10 << 20 // 单个 sitemap 文件的最大字节数
+	maxSitemapChildren = 20       // sitemap 索引中最多读取的子 sitemap 数
+	maxSitemapURLs     = 50000    // 最多读取的页面地址数
+)
+
+// sitemapDocument sitemap 文件，urlset 和 sitemapindex 两种根元素共用
+type sitemapDocument struct {
+	URLs []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// fetchSitemap 读取 sitemap 并返回其中的页面地址。sitemap 索引会展开一层子 sitemap，
+// 子 sitemap 读取失败时跳过。请求遵守 URL 访问策略。
+func (s *Service) fetchSitemap(ctx context.Context, sitemapURL string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.defaultTimeout)
+	defer cancel()
+	client := s.policy.HTTPClient(s.defaultTimeout)
+
+	doc, err := readSitemap(ctx, client, sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+	var locs []string
+	for _, u := range doc.URLs {
+		locs = append(locs, strings.TrimSpace(u.Loc))
+	}
+	for i, child := range doc.Sitemaps {
+		if i >= maxSitemapChildren || len(locs) >= maxSitemapURLs {
+			break
+		}
+		childDoc, err := readSitemap(ctx, client, strings.TrimSpace(child.Loc))
+		if err != nil {
+			log.Printf("跳过子 sitemap: %v", err)
+			continue
+		}
+		for _, u := range childDoc.URLs {
+			locs = append(locs, strings.TrimSpace(u.Loc))
+		}
+	}
+	if len(locs) > maxSitemapURLs {
+		locs = locs[:maxSitemapURLs]
+	}
+	return locs, nil
+}
+
+// readSitemap 下载并解析一个 sitemap 文件
+func readSitemap(ctx context.Context, client *http.Client, sitemapURL string) (*sitemapDocument, error) {
+	// wrap 保留访问策略和域名解析错误（包括重定向时被拒绝的），其余请求失败视为页面加载失败
+	wrap := func(err error) error {
+		var violation *urlpolicy.Violation
+		var dnsErr *net.DNSError
+		var urlErr *url.Error
+		switch {
+		case errors.As(err, &violation), errors.As(err, &dnsErr):
+		case errors.As(err, &urlErr):
+			err = newPhaseError(ctx, PhaseNavigation, &navigationError{text: err.Error()})
+		default:
+			err = newPhaseError(ctx, PhaseNavigation, err)
+		}
+		return fmt.Errorf("读取 sitemap %s 失败: %w", sitemapURL, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sitemapURL, nil)
+	if err != nil {
+		return nil, wrap(err)
+	}
+	req.Header.Set("Accept", "application/xml, text/xml")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, wrap(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, wrap(&httpStatusError{status: int64(resp.StatusCode), text: http.StatusText(resp.StatusCode)})
+	}
+
+	var doc sitemapDocument
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, maxSitemapSize)).Decode(&doc); err != nil {
+		return nil, wrap(fmt.Errorf("解析 XML 失败: %w", err))
+	}
+	return &doc, nil
+}
@@ -0,0 +1,167 @@
+package screenshot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gotoailab/snapup/internal/models"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// waitPollInterval 轮询选择器和表达式的间隔
+const waitPollInterval = 100 * time.Millisecond
+
+// waitConditions 依次等待所有条件满足，每个条件使用各自的超时时间
+func waitConditions(w *pageWatcher, conds []models.WaitCondition) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		for _, cond := range conds {
+			if err := waitCondition(ctx, w, cond); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// waitCondition 等待单个条件满足
+func waitCondition(ctx context.Context, w *pageWatcher, cond models.WaitCondition) error {
+	timeout := time.Duration(cond.Timeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = models.DefaultWaitTimeout * time.Millisecond
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var err error
+	switch cond.Type {
+	case models.WaitLoad:
+		select {
+		case <-w.loaded:
+		case <-waitCtx.Done():
+			err = waitCtx.Err()
+		}
+	case models.WaitNetworkIdle:
+		idle := time.Duration(cond.IdleTime) * time.Millisecond
+		err = poll(waitCtx, func(context.Context) (bool, error) {
+			return w.idleFor(idle), nil
+		})
+	case models.WaitSelector:
+		err = pollJS(waitCtx, fmt.Sprintf(selectorVisibleJS, jsString(cond.Value)))
+	case models.WaitExpression:
+		err = pollJS(waitCtx, fmt.Sprintf(`Promise.resolve((%s)).then(v => !!v)`, cond.Value))
+	case models.WaitFonts:
+		var ready bool
+		err = chromedp.Evaluate(`document.fonts.ready.then(() => true)`, &ready, awaitPromise).Do(waitCtx)
+	default:
+		return fmt.Errorf("不支持的等待条件: %s", cond.Type)
+	}
+
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		msg := fmt.Sprintf("条件 %s 在 %d 毫秒内未满足", describeWait(cond), cond.Timeout)
+		var pe *pollError
+		if errors.As(err, &pe) {
+			msg += fmt.Sprintf("，最后一次错误: %v", pe.last)
+		}
+		return waitTimeoutError(msg)
+	}
+	return fmt.Errorf("等待条件 %s 失败: %w", describeWait(cond), err)
+}
+
+// selectorVisibleJS 判断选择器匹配的元素是否可见
+const selectorVisibleJS = `(() => {
+	const el = document.querySelector(%s);
+	if (!el) return false;
+	const rect = el.getBoundingClientRect();
+	const style = getComputedStyle(el);
+	return rect.width > 0 && rect.height > 0 && style.visibility !== 'hidden' && style.display !== 'none';
+})()`
+
+// pollJS 轮询执行 JS 表达式直到返回 true。
+// 页面跳转等导致的执行错误会被忽略并继续轮询，超时后返回最后一次的错误。
+func pollJS(ctx context.Context, expression string) error {
+	return poll(ctx, func(ctx context.Context) (bool, error) {
+		var ok bool
+		if err := chromedp.Evaluate(expression, &ok, awaitPromise).Do(ctx); err != nil {
+			return false, err
+		}
+		return ok, nil
+	})
+}
+
+// poll 按固定间隔检查条件，直到条件满足或 ctx 结束
+func poll(ctx context.Context, check func(context.Context) (bool, error)) error {
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		ok, err := check(ctx)
+		if ok {
+			return nil
+		}
+		if err != nil && ctx.Err() == nil {
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return &pollError{err: ctx.Err(), last: lastErr}
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitTimeoutError 等待条件超时，可用 errors.Is(err, context.DeadlineExceeded) 判断
+type waitTimeoutError string
+
+func (e waitTimeoutError) Error() string {
+	return string(e)
+}
+
+func (e waitTimeoutError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// pollError 轮询结束时附带最后一次检查的错误
+type pollError struct {
+	err  error
+	last error
+}
+
+func (e *pollError) Error() string {
+	return fmt.Sprintf("%v（最后一次错误: %v）", e.err, e.last)
+}
+
+func (e *pollError) Unwrap() error {
+	return e.err
+}
+
+// awaitPromise 让 Evaluate 等待 Promise 完成
+func awaitPromise(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+	return p.WithAwaitPromise(true)
+}
+
+// jsString 将字符串编码为 JS 字符串字面量
+func jsString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// describeWait 返回等待条件的可读描述
+func describeWait(cond models.WaitCondition) string {
+	if cond.Value != "" {
+		return fmt.Sprintf("%s(%s)", cond.Type, cond.Value)
+	}
+	return string(cond.Type)
+}
@@ -1,11 +1,15 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/gotoailab/snapup/internal/jobs"
 	"github.com/gotoailab/snapup/internal/models"
 	"github.com/gotoailab/snapup/internal/screenshot"
 )
@@ -13,12 +17,14 @@ import (
 // Handler HTTP 处理器
 type Handler struct {
 	screenshotService *screenshot.Service
+	jobs              *jobs.Manager
 }
 
 // NewHandler 创建处理器
 func NewHandler(screenshotService *screenshot.Service) *Handler {
 	return &Handler{
 		screenshotService: screenshotService,
+		jobs:              jobs.NewManager(jobs.ConfigFromEnv()),
 	}
 }
 
@@ -32,7 +38,11 @@ func (h *Handler) HandleScreenshot(w http.ResponseWriter, r *http.Request) {
 	// 解析请求
 	var req models.ScreenshotRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendJSONError(w, "无效的请求格式", http.StatusBadRequest)
+		h.sendJSON(w, &models.ScreenshotResponse{
+			Success: false,
+			Message: "无效的请求格式",
+			Code:    models.CodeInvalidRequest,
+		}, http.StatusBadRequest)
 		return
 	}
 
@@ -46,38 +56,438 @@ func (h *Handler) HandleScreenshot(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 返回响应
+	// 返回响应，失败时按错误码设置 HTTP 状态码
+	status := http.StatusOK
+	if !resp.Success {
+		status = statusForCode(resp.Code)
+		log.Printf("截图失败 [%s]: %s", resp.Code, resp.Message)
+	}
+	if resp.Code == models.CodeConcurrencyLimit || resp.Code == models.CodeQueueTimeout {
+		w.Header().Set("Retry-After", "1")
+	}
+	h.sendJSON(w, resp, status)
+}
+
+// HandleJobs 提交异步截图任务，立即返回任务 ID，截图在后台执行
+func (h *Handler) HandleJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.ScreenshotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendJSON(w, &models.ScreenshotResponse{
+			Success: false,
+			Message: "无效的请求格式",
+			Code:    models.CodeInvalidRequest,
+		}, http.StatusBadRequest)
+		return
+	}
+
+	// 提前拒绝无效请求，避免创建注定失败的任务
+	if err := h.screenshotService.Validate(r.Context(), req); err != nil {
+		code := screenshot.ErrorCode(err)
+		h.sendJSON(w, &models.ScreenshotResponse{
+			Success: false,
+			Message: err.Error(),
+			Code:    code,
+		}, statusForCode(code))
+		return
+	}
+
+	job, ok := h.submitJob(w, r, "screenshot", func(ctx context.Context) (interface{}, bool, error) {
+		resp, err := h.screenshotService.TakeScreenshot(ctx, req)
+		if err != nil {
+			return nil, true, err
+		}
+		return resp, !resp.Success, nil
+	})
+	if ok {
+		log.Printf("创建截图任务 %s: URL=%s, Device=%s", job.ID, req.URL, req.Device)
+	}
+}
+
+// submitJob 以当前客户端的身份提交异步任务，返回 202 和任务地址。
+// 客户端或服务器未结束的任务数达到上限时返回 429（concurrency_limit）。
+func (h *Handler) submitJob(w http.ResponseWriter, r *http.Request, jobType string, fn jobs.Func) (jobs.Job, bool) {
+	job, err := h.jobs.Submit(screenshot.ClientFromContext(r.Context()), jobType, fn)
+	if err != nil {
+		log.Printf("拒绝%s任务: %v", jobType, err)
+		w.Header().Set("Retry-After", "1")
+		h.sendJSON(w, &models.ScreenshotResponse{
+			Success: false,
+			Message: err.Error(),
+			Code:    models.CodeConcurrencyLimit,
+		}, http.StatusTooManyRequests)
+		return jobs.Job{}, false
+	}
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	h.sendJSON(w, job, http.StatusAccepted)
+	return job, true
+}
+
+// HandleBatch 批量截图，对多个 URL 和设备、样式的每个组合截图，返回每项结果和整体状态。
+// async 为 true 时以异步任务执行，立即返回任务 ID。
+func (h *Handler) HandleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendJSON(w, &models.ScreenshotResponse{
+			Success: false,
+			Message: "无效的请求格式",
+			Code:    models.CodeInvalidRequest,
+		}, http.StatusBadRequest)
+		return
+	}
+	if err := h.screenshotService.ValidateBatch(req); err != nil {
+		code := screenshot.ErrorCode(err)
+		h.sendJSON(w, &models.ScreenshotResponse{
+			Success: false,
+			Message: err.Error(),
+			Code:    code,
+		}, statusForCode(code))
+		return
+	}
+
+	log.Printf("收到批量截图请求: %d 个 URL, Devices=%v, Styles=%v, Async=%v", len(req.URLs), req.Devices, req.Styles, req.Async)
+
+	if req.Async {
+		h.submitJob(w, r, "batch", func(ctx context.Context) (interface{}, bool, error) {
+			resp := h.screenshotService.TakeBatch(ctx, req, func(done, total int) {
+				jobs.ReportProgress(ctx, done, total)
+			})
+			return resp, resp.Status == models.BatchFailed, nil
+		})
+		return
+	}
+
+	// 批量截图可能超过服务器的写超时，取消本次响应的写超时
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("取消批量截图响应的写超时失败: %v", err)
+	}
+	resp := h.screenshotService.TakeBatch(r.Context(), req, nil)
+	log.Printf("批量截图完成: %s，成功 %d，失败 %d", resp.Status, resp.Succeeded, resp.Failed)
 	h.sendJSON(w, resp, http.StatusOK)
 }
 
+// HandleCrawl 提交站点爬取任务。爬取页面较多，始终以异步任务执行
+func (h *Handler) HandleCrawl(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.CrawlRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendJSON(w, &models.ScreenshotResponse{
+			Success: false,
+			Message: "无效的请求格式",
+			Code:    models.CodeInvalidRequest,
+		}, http.StatusBadRequest)
+		return
+	}
+	if err := h.screenshotService.ValidateCrawl(r.Context(), req); err != nil {
+		code := screenshot.ErrorCode(err)
+		h.sendJSON(w, &models.ScreenshotResponse{
+			Success: false,
+			Message: err.Error(),
+			Code:    code,
+		}, statusForCode(code))
+		return
+	}
+
+	log.Printf("收到站点爬取请求: URL=%s, Sitemap=%v, MaxPages=%d", req.URL, req.Sitemap, req.MaxPages)
+
+	h.submitJob(w, r, "crawl", func(ctx context.Context) (interface{}, bool, error) {
+		resp := h.screenshotService.Crawl(ctx, req, func(done, total int) {
+			jobs.ReportProgress(ctx, done, total)
+		})
+		log.Printf("站点爬取完成: %s，%d 个页面，成功 %d，失败 %d", req.URL, resp.Total, resp.Succeeded, resp.Failed)
+		return resp, resp.Status == models.BatchFailed, nil
+	})
+}
+
+// HandleComposite 处理多设备合成图请求
+func (h *Handler) HandleComposite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.CompositeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendJSON(w, &models.CompositeResponse{
+			Success: false,
+			Message: "无效的请求格式",
+			Code:    models.CodeInvalidRequest,
+		}, http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("收到合成图请求: URL=%s, Devices=%v, Layout=%s", req.URL, req.Devices, req.Layout)
+
+	// 多个设备的截图和合成可能超过服务器的写超时，取消本次响应的写超时
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("取消合成图响应的写超时失败: %v", err)
+	}
+	resp := h.screenshotService.TakeComposite(r.Context(), req)
+
+	status := http.StatusOK
+	if !resp.Success {
+		status = statusForCode(resp.Code)
+		log.Printf("合成图失败 [%s]: %s", resp.Code, resp.Message)
+	}
+	if resp.Code == models.CodeConcurrencyLimit || resp.Code == models.CodeQueueTimeout {
+		w.Header().Set("Retry-After", "1")
+	}
+	h.sendJSON(w, resp, status)
+}
+
+// HandleDiff 处理截图对比请求
+func (h *Handler) HandleDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.DiffRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendJSON(w, &models.DiffResponse{
+			Success: false,
+			Message: "无效的请求格式",
+			Code:    models.CodeInvalidRequest,
+		}, http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("收到截图对比请求: Before=%s, After=%s", describeDiffSource(req.Before), describeDiffSource(req.After))
+
+	// 两侧截图和逐像素对比可能超过服务器的写超时，取消本次响应的写超时
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("取消截图对比响应的写超时失败: %v", err)
+	}
+	resp := h.screenshotService.Diff(r.Context(), req)
+
+	status := http.StatusOK
+	if !resp.Success {
+		status = statusForCode(resp.Code)
+		log.Printf("截图对比失败 [%s]: %s", resp.Code, resp.Message)
+	}
+	if resp.Code == models.CodeConcurrencyLimit || resp.Code == models.CodeQueueTimeout {
+		w.Header().Set("Retry-After", "1")
+	}
+	h.sendJSON(w, resp, status)
+}
+
+// describeDiffSource 返回对比来源在日志中的描述
+func describeDiffSource(src models.DiffSource) string {
+	if src.Filename != "" {
+		return "file:" + src.Filename
+	}
+	return src.URL
+}
+
+// HandleBaselines 查询基线列表（GET，可按 name 过滤）或保存基线（POST）
+func (h *Handler) HandleBaselines(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.sendJSON(w, map[string]interface{}{
+			"baselines": h.screenshotService.Baselines(r.Context(), r.URL.Query().Get("name")),
+		}, http.StatusOK)
+	case http.MethodPost:
+		var req models.BaselineRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.sendJSON(w, &models.BaselineResponse{
+				Success: false,
+				Message: "无效的请求格式",
+				Code:    models.CodeInvalidRequest,
+			}, http.StatusBadRequest)
+			return
+		}
+		log.Printf("收到保存基线请求: Name=%s, URL=%s, Device=%s", req.Name, req.URL, req.Device)
+		h.sendBaselineResponse(w, h.screenshotService.SaveBaseline(r.Context(), req))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleBaseline 处理单个基线和批量检查：
+// GET、DELETE /api/baselines/{id}，POST /api/baselines/{id}/compare、/api/baselines/{id}/approve，
+// POST /api/baselines/check
+func (h *Handler) HandleBaseline(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/baselines/")
+	if path == "check" {
+		h.handleBaselineCheck(w, r)
+		return
+	}
+	id, action, _ := strings.Cut(path, "/")
+	if id == "" || strings.Contains(action, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		h.sendBaselineResponse(w, h.screenshotService.GetBaseline(r.Context(), id))
+	case action == "" && r.Method == http.MethodDelete:
+		log.Printf("删除基线 %s", id)
+		h.sendBaselineResponse(w, h.screenshotService.DeleteBaseline(r.Context(), id))
+	case action == "compare" && r.Method == http.MethodPost:
+		log.Printf("收到基线对比请求: %s", id)
+		// 截图和逐像素对比可能超过服务器的写超时，取消本次响应的写超时
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+			log.Printf("取消基线对比响应的写超时失败: %v", err)
+		}
+		resp := h.screenshotService.CompareBaseline(r.Context(), id)
+		status := http.StatusOK
+		if !resp.Success {
+			status = statusForCode(resp.Code)
+			log.Printf("基线对比失败 [%s]: %s", resp.Code, resp.Message)
+		}
+		if resp.Code == models.CodeConcurrencyLimit || resp.Code == models.CodeQueueTimeout {
+			w.Header().Set("Retry-After", "1")
+		}
+		h.sendJSON(w, resp, status)
+	case action == "approve" && r.Method == http.MethodPost:
+		var req models.BaselineApproveRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				h.sendJSON(w, &models.BaselineResponse{
+					Success: false,
+					Message: "无效的请求格式",
+					Code:    models.CodeInvalidRequest,
+				}, http.StatusBadRequest)
+				return
+			}
+		}
+		log.Printf("批准基线 %s，对比记录 %q", id, req.RunID)
+		h.sendBaselineResponse(w, h.screenshotService.ApproveBaseline(r.Context(), id, req.RunID))
+	case action == "" || action == "compare" || action == "approve":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleBaselineCheck 对基线集中的所有基线重新截图并对比，返回每个页面是否通过。
+// 有页面未通过时 status 为 failed，HTTP 状态码仍为 200
+func (h *Handler) handleBaselineCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.BaselineCheckRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.sendJSON(w, &models.BaselineResponse{
+				Success: false,
+				Message: "无效的请求格式",
+				Code:    models.CodeInvalidRequest,
+			}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	log.Printf("收到基线检查请求: Name=%s, Async=%v", req.Name, req.Async)
+
+	if req.Async {
+		h.submitJob(w, r, "baseline_check", func(ctx context.Context) (interface{}, bool, error) {
+			resp := h.screenshotService.CheckBaselines(ctx, req, func(done, total int) {
+				jobs.ReportProgress(ctx, done, total)
+			})
+			log.Printf("基线检查完成: %s，通过 %d，未通过 %d，失败 %d", resp.Status, resp.Passed, resp.Failed, resp.Errors)
+			return resp, resp.Total > 0 && resp.Errors == resp.Total, nil
+		})
+		return
+	}
+
+	// 检查多个页面可能超过服务器的写超时，取消本次响应的写超时
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("取消基线检查响应的写超时失败: %v", err)
+	}
+	resp := h.screenshotService.CheckBaselines(r.Context(), req, nil)
+	log.Printf("基线检查完成: %s，通过 %d，未通过 %d，失败 %d", resp.Status, resp.Passed, resp.Failed, resp.Errors)
+	h.sendJSON(w, resp, http.StatusOK)
+}
+
+// sendBaselineResponse 发送基线响应，失败时按错误码设置 HTTP 状态码
+func (h *Handler) sendBaselineResponse(w http.ResponseWriter, resp *models.BaselineResponse) {
+	status := http.StatusOK
+	if !resp.Success {
+		status = statusForCode(resp.Code)
+		log.Printf("基线操作失败 [%s]: %s", resp.Code, resp.Message)
+	}
+	if resp.Code == models.CodeConcurrencyLimit || resp.Code == models.CodeQueueTimeout {
+		w.Header().Set("Retry-After", "1")
+	}
+	h.sendJSON(w, resp, status)
+}
+
+// HandleJob 查询（GET）或取消（DELETE）异步任务
+func (h *Handler) HandleJob(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/jobs/")
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	owner := screenshot.ClientFromContext(r.Context())
+
+	var job jobs.Job
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		job, err = h.jobs.Get(owner, id)
+	case http.MethodDelete:
+		job, err = h.jobs.Cancel(owner, id)
+		if err == nil {
+			log.Printf("取消任务 %s，当前状态 %s", id, job.Status)
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		h.sendJSON(w, &models.ScreenshotResponse{
+			Success: false,
+			Message: err.Error(),
+			Code:    models.CodeNotFound,
+		}, http.StatusNotFound)
+		return
+	}
+	h.sendJSON(w, job, http.StatusOK)
+}
+
+// statusForCode 返回错误码对应的 HTTP 状态码
+func statusForCode(code models.ErrorCode) int {
+	switch code {
+	case models.CodeInvalidURL, models.CodeInvalidRequest:
+		return http.StatusBadRequest
+	case models.CodeURLBlocked:
+		return http.StatusForbidden
+	case models.CodeNotFound:
+		return http.StatusNotFound
+	case models.CodeRateLimited, models.CodeConcurrencyLimit:
+		return http.StatusTooManyRequests
+	case models.CodeDNSFailure, models.CodeHTTPErrorStatus, models.CodeNavigationFailed:
+		return http.StatusBadGateway
+	case models.CodeNavigationTimeout:
+		return http.StatusGatewayTimeout
+	case models.CodeBrowserUnavailable, models.CodeQueueTimeout:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // HandleDevices 获取支持的设备列表
 func (h *Handler) HandleDevices(w http.ResponseWriter, r *http.Request) {
-	devices := []map[string]interface{}{
-		{
-			"type":   string(models.DeviceDesktop),
-			"name":   "桌面",
-			"width":  1920,
-			"height": 1080,
-		},
-		{
-			"type":   string(models.DeviceLaptop),
-			"name":   "笔记本",
-			"width":  1440,
-			"height": 900,
-		},
-		{
-			"type":   string(models.DeviceTablet),
-			"name":   "平板",
-			"width":  768,
-			"height": 1024,
-		},
-		{
-			"type":   string(models.DeviceMobile),
-			"name":   "手机",
-			"width":  375,
-			"height": 812,
-		},
-	}
+	devices := h.screenshotService.Devices().List()
 
 	h.sendJSON(w, map[string]interface{}{
 		"devices": devices,
@@ -95,12 +505,12 @@ func (h *Handler) HandleStyles(w http.ResponseWriter, r *http.Request) {
 		{
 			"type":        string(models.StyleGlass),
 			"name":        "玻璃风格",
-			"description": "半透明玻璃边框效果",
+			"description": "模糊背景上的半透明玻璃卡片",
 		},
 		{
 			"type":        string(models.StyleDevice),
 			"name":        "设备边框",
-			"description": "模拟设备外壳边框",
+			"description": "按设备类型套用手机、平板、笔记本或显示器外框",
 		},
 		{
 			"type":        string(models.StyleFloating),
@@ -116,10 +526,16 @@ func (h *Handler) HandleStyles(w http.ResponseWriter, r *http.Request) {
 
 // HandleHealth 健康检查
 func (h *Handler) HandleHealth(w http.ResponseWriter, r *http.Request) {
-	h.sendJSON(w, map[string]interface{}{
+	health := map[string]interface{}{
 		"status":  "ok",
 		"service": "snapup",
-	}, http.StatusOK)
+	}
+	if stats, ok := h.screenshotService.PoolStats(); ok {
+		health["browser_pool"] = stats
+	}
+	health["capture_queue"] = h.screenshotService.QueueStats()
+
+	h.sendJSON(w, health, http.StatusOK)
 }
 
 // sendJSON 发送 JSON 响应
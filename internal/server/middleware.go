@@ -1,11 +1,24 @@
 package server
 
 import (
+	"encoding/json"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/gotoailab/snapup/internal/auth"
+	"github.com/gotoailab/snapup/internal/models"
+	"github.com/gotoailab/snapup/internal/ratelimit"
+	"github.com/gotoailab/snapup/internal/screenshot"
 )
 
+// apiKeyParam 携带 API Key 的查询参数名
+const apiKeyParam = "api_key"
+
 // LoggingMiddleware 日志中间件
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -20,13 +33,25 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 		log.Printf(
 			"%s %s %d %s",
 			r.Method,
-			r.RequestURI,
+			redactedURI(r),
 			sw.status,
 			duration,
 		)
 	})
 }
 
+// redactedURI 返回隐去 API Key 的请求 URI，避免 Key 写入日志
+func redactedURI(r *http.Request) string {
+	query := r.URL.Query()
+	if !query.Has(apiKeyParam) {
+		return r.RequestURI
+	}
+	query.Set(apiKeyParam, "REDACTED")
+	u := *r.URL
+	u.RawQuery = query.Encode()
+	return u.RequestURI()
+}
+
 // statusWriter 包装 ResponseWriter 以捕获状态码
 type statusWriter struct {
 	http.ResponseWriter
@@ -38,12 +63,18 @@ func (sw *statusWriter) WriteHeader(status int) {
 	sw.ResponseWriter.WriteHeader(status)
 }
 
+// Unwrap 返回原始的 ResponseWriter，供 http.ResponseController 使用
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
 // CORSMiddleware CORS 中间件
 func CORSMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
+		w.Header().Set("Access-Control-Expose-Headers", "Retry-After, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -66,3 +97,118 @@ func RecoveryMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// AuthMiddleware API Key 认证中间件，要求请求携带拥有 scope 权限的 Key，scope 为空时只要求认证通过。
+// Key 可以通过 X-API-Key 请求头、Authorization: Bearer 请求头或 api_key 查询参数传递。
+// 未配置任何 Key 时不启用认证。
+func AuthMiddleware(keys *auth.KeyStore, scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !keys.Enabled() {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			provided := apiKeyFromRequest(r)
+			if provided == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="snapup"`)
+				writeErrorJSON(w, http.StatusUnauthorized, models.CodeUnauthorized, "缺少 API Key")
+				return
+			}
+
+			key := keys.Authenticate(provided)
+			if key == nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="snapup", error="invalid_token"`)
+				writeErrorJSON(w, http.StatusUnauthorized, models.CodeUnauthorized, "无效的 API Key")
+				return
+			}
+			if !key.HasScope(scope) {
+				log.Printf("API Key %s 缺少权限 %s: %s %s", key.Name, scope, r.Method, r.URL.Path)
+				writeErrorJSON(w, http.StatusForbidden, models.CodeForbidden, "API Key 没有 "+scope+" 权限")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithKey(r.Context(), key)))
+		})
+	}
+}
+
+// apiKeyFromRequest 从请求头或查询参数中读取 API Key
+func apiKeyFromRequest(r *http.Request) string {
+	if key := strings.TrimSpace(r.Header.Get("X-API-Key")); key != "" {
+		return key
+	}
+	if authz := r.Header.Get("Authorization"); len(authz) > 7 && strings.EqualFold(authz[:7], "Bearer ") {
+		return strings.TrimSpace(authz[7:])
+	}
+	return r.URL.Query().Get(apiKeyParam)
+}
+
+// RateLimitMiddleware 限流中间件，按客户端（认证通过的 API Key，否则为 IP）的令牌桶限制请求速率，
+// 并在 context 中标记客户端，供截图服务限制每个客户端的并发截图数。limiter 为 nil 时不限流。
+// 需放在 AuthMiddleware 之后。trustProxy 为 true 时从 X-Forwarded-For / X-Real-IP 读取客户端 IP。
+// 轮询任务状态（GET /api/jobs/{id}）不消耗令牌，避免客户端在等待异步任务时被限流。
+func RateLimitMiddleware(limiter *ratelimit.Limiter, trustProxy bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client := clientID(r, trustProxy)
+
+			if limiter != nil && !isJobPoll(r) {
+				result := limiter.Allow(client)
+				w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+				w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+				w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+				if !result.Allowed {
+					w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+					writeErrorJSON(w, http.StatusTooManyRequests, models.CodeRateLimited, "请求过于频繁，请稍后重试")
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(screenshot.WithClient(r.Context(), client)))
+		})
+	}
+}
+
+// isJobPoll 判断请求是否为查询单个异步任务的状态
+func isJobPoll(r *http.Request) bool {
+	return r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/api/jobs/")
+}
+
+// clientID 返回用于限流的客户端标识。
+// 信任代理头时取 X-Forwarded-For 最右侧的地址：它由最近一层代理追加，左侧的条目可能由客户端伪造。
+func clientID(r *http.Request, trustProxy bool) string {
+	if key := auth.FromContext(r.Context()); key != nil {
+		return "key:" + key.Name
+	}
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			entries := strings.Split(forwarded, ",")
+			if ip := strings.TrimSpace(entries[len(entries)-1]); ip != "" {
+				return "ip:" + ip
+			}
+		}
+		if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+			return "ip:" + ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// ceilSeconds 将时长向上取整为秒，用于 Retry-After 等响应头
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// writeErrorJSON 发送中间件拒绝请求时的 JSON 错误响应
+func writeErrorJSON(w http.ResponseWriter, status int, code models.ErrorCode, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": message,
+		"code":    code,
+	})
+}
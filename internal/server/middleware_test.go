@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gotoailab/snapup/internal/ratelimit"
+)
+
+func TestClientID(t *testing.T) {
+	tests := []struct {
+		name       string
+		trustProxy bool
+		forwarded  string
+		realIP     string
+		want       string
+	}{
+		{"不信任代理头", false, "1.1.1.1", "2.2.2.2", "ip:192.0.2.1"},
+		{"取最右侧的转发地址", true, "6.6.6.6, 1.1.1.1", "2.2.2.2", "ip:1.1.1.1"},
+		{"单个转发地址", true, "1.1.1.1", "", "ip:1.1.1.1"},
+		{"最右侧为空时使用 X-Real-IP", true, "6.6.6.6, ", "2.2.2.2", "ip:2.2.2.2"},
+		{"只有 X-Real-IP", true, "", "2.2.2.2", "ip:2.2.2.2"},
+		{"没有代理头", true, "", "", "ip:192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/screenshot", nil)
+			r.RemoteAddr = "192.0.2.1:1234"
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := clientID(r, tt.trustProxy); got != tt.want {
+				t.Fatalf("clientID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitMiddlewareSkipsJobPolling(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Config{PerMinute: 1, Burst: 1})
+	handler := RateLimitMiddleware(limiter, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{http.MethodPost, "/api/screenshot", http.StatusOK},
+		{http.MethodGet, "/api/jobs/abc", http.StatusOK},
+		{http.MethodGet, "/api/jobs/abc", http.StatusOK},
+		{http.MethodDelete, "/api/jobs/abc", http.StatusTooManyRequests},
+		{http.MethodGet, "/api/jobs", http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Fatalf("%s %s = %d, want %d", tt.method, tt.path, w.Code, tt.want)
+		}
+	}
+}
@@ -6,8 +6,15 @@ import (
 	"io/fs"
 	"log"
 	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/gotoailab/snapup/internal/auth"
+	"github.com/gotoailab/snapup/internal/models"
+	"github.com/gotoailab/snapup/internal/ratelimit"
 	"github.com/gotoailab/snapup/internal/screenshot"
 )
 
@@ -32,6 +39,24 @@ func NewServer(screenshotService *screenshot.Service, port int) *Server {
 func (s *Server) Start() error {
 	mux := http.NewServeMux()
 
+	// API Key 认证
+	keys, err := auth.LoadFromEnv()
+	if err != nil {
+		return fmt.Errorf("加载 API Key 失败: %w", err)
+	}
+	if keys.Enabled() {
+		log.Printf("API Key 认证已启用，共 %d 个 Key", keys.Len())
+	} else {
+		log.Printf("警告: 未配置 API Key，API 接口无需认证即可访问")
+	}
+
+	// 按客户端限流，放在认证之后以便按 API Key 区分客户端
+	limiter := ratelimit.New(ratelimit.ConfigFromEnv())
+	trustProxy, _ := strconv.ParseBool(os.Getenv("TRUST_PROXY_HEADERS"))
+	protect := func(scope string, h http.HandlerFunc) http.Handler {
+		return AuthMiddleware(keys, scope)(RateLimitMiddleware(limiter, trustProxy)(h))
+	}
+
 	// 静态文件服务
 	staticFS, err := fs.Sub(staticFiles, "static")
 	if err != nil {
@@ -56,24 +81,32 @@ func (s *Server) Start() error {
 	// 静态资源
 	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(staticFS))))
 
-	// API 路由
-	mux.HandleFunc("/api/screenshot", s.handler.HandleScreenshot)
-	mux.HandleFunc("/api/devices", s.handler.HandleDevices)
-	mux.HandleFunc("/api/styles", s.handler.HandleStyles)
+	// API 路由，健康检查不需要认证
+	mux.Handle("/api/screenshot", protect(auth.ScopeScreenshot, s.handler.HandleScreenshot))
+	mux.Handle("/api/batch", protect(auth.ScopeScreenshot, s.handler.HandleBatch))
+	mux.Handle("/api/composite", protect(auth.ScopeScreenshot, s.handler.HandleComposite))
+	mux.Handle("/api/diff", protect(auth.ScopeScreenshot, s.handler.HandleDiff))
+	mux.Handle("/api/baselines", protect(auth.ScopeScreenshot, s.handler.HandleBaselines))
+	mux.Handle("/api/baselines/", protect(auth.ScopeScreenshot, s.handler.HandleBaseline))
+	mux.Handle("/api/crawl", protect(auth.ScopeScreenshot, s.handler.HandleCrawl))
+	mux.Handle("/api/jobs", protect(auth.ScopeScreenshot, s.handler.HandleJobs))
+	mux.Handle("/api/jobs/", protect(auth.ScopeScreenshot, s.handler.HandleJob))
+	mux.Handle("/api/devices", protect(auth.ScopeRead, s.handler.HandleDevices))
+	mux.Handle("/api/styles", protect(auth.ScopeRead, s.handler.HandleStyles))
 	mux.HandleFunc("/api/health", s.handler.HandleHealth)
 
-	// 截图文件服务
-	mux.Handle("/screenshots/", http.StripPrefix("/screenshots/", http.FileServer(http.Dir("./screenshots"))))
+	// 截图文件服务，文件名包含随机 UUID，不需要认证
+	mux.Handle("/screenshots/", http.StripPrefix("/screenshots/", screenshotFileServer(http.Dir("./screenshots"))))
 
 	// 应用中间件
 	handler := RecoveryMiddleware(LoggingMiddleware(CORSMiddleware(mux)))
 
-	// 创建 HTTP 服务器
+	// 创建 HTTP 服务器，写超时需要大于截图允许的最大超时时间
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", s.port),
 		Handler:      handler,
 		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
+		WriteTimeout: s.handler.screenshotService.MaxTimeout() + 30*time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
 
@@ -82,3 +115,21 @@ func (s *Server) Start() error {
 
 	return server.ListenAndServe()
 }
+
+// screenshotFileServer 截图文件服务，按扩展名设置图片的 Content-Type。
+// 以点开头的文件（如基线记录）不对外提供
+func screenshotFileServer(root http.FileSystem) http.Handler {
+	fileServer := http.FileServer(root)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, segment := range strings.Split(r.URL.Path, "/") {
+			if strings.HasPrefix(segment, ".") {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		if format, ok := models.FormatFromExtension(strings.ToLower(path.Ext(r.URL.Path))); ok {
+			w.Header().Set("Content-Type", format.MimeType())
+		}
+		fileServer.ServeHTTP(w, r)
+	})
+}
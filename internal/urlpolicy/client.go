@@ -0,0 +1,61 @@
+package urlpolicy
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// maxRedirects HTTP 客户端最多跟随的重定向次数
+const maxRedirects = 10
+
+// HTTPClient 返回遵守访问策略的 HTTP 客户端，用于截图之外需要直接请求目标站点的场景（如读取 sitemap）。
+// 每个请求（包括重定向）发出前检查 URL，建立连接时再检查实际连接的 IP，防止 DNS 重绑定。
+// 客户端不使用环境变量中的代理，否则无法检查实际访问的地址。
+func (p *Policy) HTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if reason := p.CheckIP(net.ParseIP(host)); reason != "" {
+				return &Violation{URL: address, Reason: reason}
+			}
+			return nil
+		},
+	}
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     30 * time.Second,
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &checkedTransport{policy: p, next: transport},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("重定向次数过多")
+			}
+			return nil
+		},
+	}
+}
+
+// checkedTransport 发出请求前按访问策略检查 URL
+type checkedTransport struct {
+	policy *Policy
+	next   http.RoundTripper
+}
+
+func (t *checkedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.policy.Check(req.Context(), req.URL.String()); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,286 @@
+// Package urlpolicy 限制截图服务可以访问的 URL，防止通过截图请求访问内网服务（SSRF）
+package urlpolicy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Config URL 访问策略配置
+type Config struct {
+	AllowedSchemes []string // 允许的协议，为空时只允许 http 和 https
+	AllowPrivate   bool     // 是否允许访问私有、回环和链路本地地址
+	AllowHosts     []string // 主机白名单，非空时只允许访问匹配的主机，支持 *.example.com 形式的通配符
+	DenyHosts      []string // 主机黑名单，优先于白名单，支持通配符
+	DisableWorkers bool     // 是否禁用页面中的 Worker 和 Service Worker 注册，Worker 中的 WebSocket 无法按策略检查
+}
+
+// ConfigFromEnv 从环境变量读取 URL 访问策略配置
+func ConfigFromEnv() Config {
+	allowPrivate, _ := strconv.ParseBool(os.Getenv("URL_ALLOW_PRIVATE_NETWORKS"))
+	disableWorkers, _ := strconv.ParseBool(os.Getenv("URL_DISABLE_WORKERS"))
+	return Config{
+		AllowedSchemes: splitList(os.Getenv("URL_ALLOWED_SCHEMES")),
+		AllowPrivate:   allowPrivate,
+		AllowHosts:     splitList(os.Getenv("URL_ALLOW_HOSTS")),
+		DenyHosts:      splitList(os.Getenv("URL_DENY_HOSTS")),
+		DisableWorkers: disableWorkers,
+	}
+}
+
+// Violation URL 违反访问策略
+type Violation struct {
+	URL    string
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("禁止访问 %s: %s", v.URL, v.Reason)
+}
+
+// Resolver 域名解析接口，便于替换默认解析器
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// Policy URL 访问策略
+type Policy struct {
+	config   Config
+	schemes  map[string]bool
+	resolver Resolver
+}
+
+// New 创建 URL 访问策略
+func New(config Config) *Policy {
+	if len(config.AllowedSchemes) == 0 {
+		config.AllowedSchemes = []string{"http", "https"}
+	}
+	schemes := make(map[string]bool, len(config.AllowedSchemes))
+	for _, scheme := range config.AllowedSchemes {
+		schemes[strings.ToLower(scheme)] = true
+	}
+	return &Policy{
+		config:   config,
+		schemes:  schemes,
+		resolver: net.DefaultResolver,
+	}
+}
+
+// Check 检查 URL 是否允许访问。主机名会被解析，任一解析结果为受限地址时拒绝访问。
+// 违反策略时返回 *Violation，域名解析失败时返回包装了 *net.DNSError 的错误。
+func (p *Policy) Check(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return &Violation{URL: rawURL, Reason: "URL 格式无效"}
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if !p.schemes[scheme] {
+		return &Violation{URL: rawURL, Reason: fmt.Sprintf("不允许的协议 %q", scheme)}
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" {
+		if scheme == "http" || scheme == "https" {
+			return &Violation{URL: rawURL, Reason: "缺少主机名"}
+		}
+		// data:、about: 等没有主机的协议不访问网络，显式允许该协议即可
+		return nil
+	}
+	if err := p.checkHost(host); err != "" {
+		return &Violation{URL: rawURL, Reason: err}
+	}
+
+	ips, err := p.resolve(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if reason := p.CheckIP(ip); reason != "" {
+			return &Violation{URL: rawURL, Reason: reason}
+		}
+	}
+	return nil
+}
+
+// DisableWorkers 是否应在页面中禁用 Worker
+func (p *Policy) DisableWorkers() bool {
+	return p.config.DisableWorkers
+}
+
+// CheckIP 检查 IP 是否为受限地址，允许访问时返回空字符串。ip 为 nil（地址未知或无效）时拒绝访问。
+func (p *Policy) CheckIP(ip net.IP) string {
+	if p.config.AllowPrivate {
+		return ""
+	}
+	if ip == nil {
+		return "无法确定实际连接的地址"
+	}
+	if reason := restrictedIP(ip); reason != "" {
+		return fmt.Sprintf("%s 是%s", ip, reason)
+	}
+	return ""
+}
+
+// checkHost 按黑白名单检查主机名，允许访问时返回空字符串
+func (p *Policy) checkHost(host string) string {
+	for _, pattern := range p.config.DenyHosts {
+		if matchHost(pattern, host) {
+			return fmt.Sprintf("主机 %s 在黑名单中", host)
+		}
+	}
+	if len(p.config.AllowHosts) == 0 {
+		return ""
+	}
+	for _, pattern := range p.config.AllowHosts {
+		if matchHost(pattern, host) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("主机 %s 不在白名单中", host)
+}
+
+// resolve 解析主机名，IP 字面量直接返回
+func (p *Policy) resolve(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	if p.config.AllowPrivate {
+		// 不限制地址时无需提前解析，交给浏览器处理
+		return nil, nil
+	}
+
+	addrs, err := p.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("解析域名 %s 失败: %w", host, err)
+	}
+	ips := make([]net.IP, len(addrs))
+	for i, addr := range addrs {
+		ips[i] = addr.IP
+	}
+	return ips, nil
+}
+
+// reservedNetworks 标准库未分类的受限网段
+var reservedNetworks = []struct {
+	network *net.IPNet
+	reason  string
+}{
+	// 部分云厂商的元数据服务位于此网段
+	{mustParseCIDR("100.64.0.0/10"), "运营商级 NAT 地址"},
+	// 0.0.0.0/8 中的地址在部分系统上等同于本机
+	{mustParseCIDR("0.0.0.0/8"), "本网络地址"},
+	{mustParseCIDR("198.18.0.0/15"), "基准测试地址"},
+	{mustParseCIDR("240.0.0.0/4"), "保留地址"},
+	// NAT64 地址内嵌 IPv4 地址，经 NAT64 网关可以访问内网
+	{mustParseCIDR("64:ff9b::/96"), "NAT64 地址"},
+	{mustParseCIDR("64:ff9b:1::/48"), "NAT64 地址"},
+}
+
+// mustParseCIDR 解析网段，格式无效时 panic，仅用于初始化常量网段
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// restrictedIP 返回 IP 所属的受限网段说明，不受限时返回空字符串
+func restrictedIP(ip net.IP) string {
+	switch {
+	case ip.IsLoopback():
+		return "回环地址"
+	case ip.IsPrivate():
+		return "私有地址"
+	case ip.IsLinkLocalUnicast(), ip.IsLinkLocalMulticast():
+		return "链路本地地址"
+	case ip.IsUnspecified():
+		return "未指定地址"
+	case ip.IsMulticast(), ip.IsInterfaceLocalMulticast():
+		return "组播地址"
+	}
+	for _, r := range reservedNetworks {
+		if r.network.Contains(ip) {
+			return r.reason
+		}
+	}
+	return ""
+}
+
+// matchHost 判断主机名是否匹配模式。*.example.com 匹配 example.com 的所有子域名，
+// 其他模式按 path.Match 的通配符规则匹配。
+func matchHost(pattern, host string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if pattern == "*" {
+		return true
+	}
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+	matched, err := path.Match(pattern, host)
+	return err == nil && matched
+}
+
+// splitList 拆分逗号分隔的列表，忽略空项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Checker 带缓存的策略检查器，用于检查同一次截图中的大量子资源请求。
+// 同一主机只解析一次，结果在检查器的生命周期内有效。
+type Checker struct {
+	policy *Policy
+
+	mu    sync.Mutex
+	cache map[string]error
+}
+
+// NewChecker 创建带缓存的策略检查器
+func (p *Policy) NewChecker() *Checker {
+	return &Checker{
+		policy: p,
+		cache:  make(map[string]error),
+	}
+}
+
+// Check 检查 URL 是否允许访问，按协议和主机缓存结果
+func (c *Checker) Check(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return c.policy.Check(ctx, rawURL)
+	}
+	key := strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Hostname())
+
+	c.mu.Lock()
+	cached, ok := c.cache[key]
+	c.mu.Unlock()
+	if ok {
+		if v, isViolation := cached.(*Violation); isViolation {
+			// 缓存中的 URL 可能是同一主机的其他地址
+			return &Violation{URL: rawURL, Reason: v.Reason}
+		}
+		return cached
+	}
+
+	err = c.policy.Check(ctx, rawURL)
+	if ctx.Err() == nil {
+		c.mu.Lock()
+		c.cache[key] = err
+		c.mu.Unlock()
+	}
+	return err
+}
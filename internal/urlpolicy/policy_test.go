@@ -0,0 +1,163 @@
+package urlpolicy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+// fakeResolver 按主机名返回固定地址的解析器，记录解析次数
+type fakeResolver struct {
+	addrs   map[string][]string
+	lookups int
+}
+
+func (r *fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	r.lookups++
+	ips, ok := r.addrs[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	addrs := make([]net.IPAddr, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.IPAddr{IP: net.ParseIP(ip)}
+	}
+	return addrs, nil
+}
+
+func TestRestrictedIP(t *testing.T) {
+	tests := []struct {
+		ip         string
+		restricted bool
+	}{
+		{"8.8.8.8", false},
+		{"93.184.216.34", false},
+		{"2606:4700::1111", false},
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"fc00::1", true},
+		{"169.254.169.254", true},
+		{"fe80::1", true},
+		{"0.0.0.0", true},
+		{"::", true},
+		{"0.1.2.3", true},
+		{"224.0.0.1", true},
+		{"100.64.0.1", true},
+		{"100.127.255.254", true},
+		{"100.128.0.1", false},
+		{"198.18.0.1", true},
+		{"198.19.255.255", true},
+		{"198.20.0.1", false},
+		{"240.0.0.1", true},
+		{"255.255.255.255", true},
+		{"64:ff9b::a00:1", true},   // NAT64 中的 10.0.0.1
+		{"64:ff9b::808:808", true}, // NAT64 中的 8.8.8.8 同样拒绝
+		{"64:ff9b:1::a00:1", true}, // 本地 NAT64 前缀
+		{"::ffff:10.0.0.1", true},  // IPv4 映射地址
+		{"::ffff:8.8.8.8", false},  // IPv4 映射的公网地址
+		{"2002:a00:1::1", false},   // 6to4 不在受限网段中
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			ip := net.ParseIP(tt.ip)
+			if ip == nil {
+				t.Fatalf("无效的测试地址 %s", tt.ip)
+			}
+			if got := restrictedIP(ip) != ""; got != tt.restricted {
+				t.Fatalf("restrictedIP(%s) = %q, want restricted = %v", tt.ip, restrictedIP(ip), tt.restricted)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	resolver := &fakeResolver{addrs: map[string][]string{
+		"example.com":         {"93.184.216.34"},
+		"api.example.com":     {"93.184.216.35"},
+		"internal.test":       {"10.0.0.5"},
+		"rebind.test":         {"93.184.216.34", "127.0.0.1"},
+		"nat64.test":          {"64:ff9b::a9fe:a9fe"},
+		"blocked.example.com": {"93.184.216.36"},
+	}}
+
+	tests := []struct {
+		name      string
+		config    Config
+		url       string
+		violation bool
+		dnsError  bool
+	}{
+		{"公网地址", Config{}, "https://example.com/path", false, false},
+		{"私有地址", Config{}, "http://internal.test/", true, false},
+		{"任一解析结果受限", Config{}, "http://rebind.test/", true, false},
+		{"NAT64 地址", Config{}, "http://nat64.test/", true, false},
+		{"IP 字面量", Config{}, "http://127.0.0.1:8080/", true, false},
+		{"IPv6 字面量", Config{}, "http://[::1]/", true, false},
+		{"元数据服务", Config{}, "http://169.254.169.254/latest/meta-data", true, false},
+		{"允许私有地址", Config{AllowPrivate: true}, "http://internal.test/", false, false},
+		{"默认不允许 file 协议", Config{}, "file:///etc/passwd", true, false},
+		{"默认不允许 data 协议", Config{}, "data:text/html,hi", true, false},
+		{"显式允许 data 协议", Config{AllowedSchemes: []string{"http", "https", "data"}}, "data:text/html,hi", false, false},
+		{"缺少主机名", Config{}, "http:///path", true, false},
+		{"白名单通配符", Config{AllowHosts: []string{"*.example.com"}}, "https://api.example.com/", false, false},
+		{"不在白名单中", Config{AllowHosts: []string{"*.example.com"}}, "https://internal.test/", true, false},
+		{"黑名单优先", Config{AllowHosts: []string{"*.example.com"}, DenyHosts: []string{"blocked.example.com"}}, "https://blocked.example.com/", true, false},
+		{"主机名大小写和末尾的点", Config{DenyHosts: []string{"example.com"}}, "https://EXAMPLE.com./", true, false},
+		{"解析失败", Config{}, "https://missing.test/", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(tt.config)
+			p.resolver = resolver
+
+			err := p.Check(context.Background(), tt.url)
+			var v *Violation
+			if got := errors.As(err, &v); got != tt.violation {
+				t.Fatalf("Check(%s) = %v, want violation = %v", tt.url, err, tt.violation)
+			}
+			var dnsErr *net.DNSError
+			if got := errors.As(err, &dnsErr); got != tt.dnsError {
+				t.Fatalf("Check(%s) = %v, want DNS error = %v", tt.url, err, tt.dnsError)
+			}
+		})
+	}
+}
+
+func TestCheckIP(t *testing.T) {
+	strict := New(Config{})
+	if reason := strict.CheckIP(nil); reason == "" {
+		t.Fatalf("无法确定地址时应拒绝")
+	}
+	if reason := strict.CheckIP(net.ParseIP("8.8.8.8")); reason != "" {
+		t.Fatalf("CheckIP(8.8.8.8) = %q", reason)
+	}
+	if reason := New(Config{AllowPrivate: true}).CheckIP(nil); reason != "" {
+		t.Fatalf("允许私有地址时不检查连接地址: %q", reason)
+	}
+}
+
+func TestCheckerCachesByHost(t *testing.T) {
+	resolver := &fakeResolver{addrs: map[string][]string{"example.com": {"93.184.216.34"}}}
+	p := New(Config{})
+	p.resolver = resolver
+	c := p.NewChecker()
+
+	for _, u := range []string{"https://example.com/a", "https://example.com/b?x=1", "https://EXAMPLE.com/c"} {
+		if err := c.Check(context.Background(), u); err != nil {
+			t.Fatalf("Check(%s) = %v", u, err)
+		}
+	}
+	if resolver.lookups != 1 {
+		t.Fatalf("lookups = %d, want 1", resolver.lookups)
+	}
+	if err := c.Check(context.Background(), "http://example.com/"); err != nil {
+		t.Fatalf("不同协议应单独检查: %v", err)
+	}
+	if resolver.lookups != 2 {
+		t.Fatalf("lookups = %d, want 2", resolver.lookups)
+	}
+}